@@ -3,6 +3,11 @@ package weaver
 import (
 	"encoding/json"
 	"fmt"
+	"time"
+
+	"github.com/gojektech/weaver/pkg/accesslog"
+	"github.com/gojektech/weaver/pkg/redact"
+	"github.com/sirupsen/logrus"
 )
 
 // ACL - Connects to an external endpoint
@@ -10,8 +15,39 @@ type ACL struct {
 	ID             string          `json:"id"`
 	Criterion      string          `json:"criterion"`
 	EndpointConfig *EndpointConfig `json:"endpoint"`
+	Headers        *HeaderConfig   `json:"headers,omitempty"`
+	Timeouts       *TimeoutConfig  `json:"timeouts,omitempty"`
+
+	// FlushIntervalInMS - How often streamed responses are flushed to the client, -1 flushes after
+	// every write and 0 keeps the reverse proxy's default
+	FlushIntervalInMS int64 `json:"flush_interval_in_ms,omitempty"`
+
+	// ErrorTemplates - Override the errors weaver answers requests routed to this ACL with
+	ErrorTemplates ErrorTemplates `json:"error_templates,omitempty"`
+
+	// Redaction - Masks more headers and query params when logging requests routed to this ACL
+	Redaction *redact.Policy `json:"redaction,omitempty"`
+
+	// AccessLog - Overrides the access log sampling for requests routed to this ACL
+	AccessLog *accesslog.SamplingOverride `json:"access_log,omitempty"`
+
+	// LogLevel - Overrides LOGGER_LEVEL for the logs of requests routed to this ACL
+	LogLevel string `json:"log_level,omitempty"`
+
+	// Plugins - Plugins run, in order, on requests routed to this ACL
+	Plugins []PluginConfig `json:"plugins,omitempty"`
 
 	Endpoint *Endpoint
+
+	// Chain - The plugins built from Plugins when the ACL is loaded
+	Chain PluginChain `json:"-"`
+
+	// RedactionPolicy - Redaction applied over the default policy when the ACL is loaded, the policy
+	// its requests are logged with
+	RedactionPolicy *redact.Policy `json:"-"`
+
+	// Level - The level parsed from LogLevel when the ACL is loaded, nil when it has none
+	Level *logrus.Level `json:"-"`
 }
 
 // GenACL - Generates an ACL from JSON
@@ -19,6 +55,15 @@ func (acl *ACL) GenACL(val string) error {
 	return json.Unmarshal([]byte(val), &acl)
 }
 
+// FlushInterval - The flush interval for responses proxied under this ACL
+func (acl ACL) FlushInterval() time.Duration {
+	if acl.FlushIntervalInMS < 0 {
+		return -1
+	}
+
+	return time.Duration(acl.FlushIntervalInMS) * time.Millisecond
+}
+
 func (acl ACL) String() string {
 	return fmt.Sprintf("ACL(%s, %s)", acl.ID, acl.Criterion)
 }
@@ -1,13 +1,16 @@
 package weaver
 
 import (
-	"net"
+	"crypto/tls"
+	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"time"
 
 	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/gojektech/weaver/pkg/requestid"
 	"github.com/pkg/errors"
 )
 
@@ -15,10 +18,25 @@ type Backend struct {
 	Handler http.Handler
 	Server  *url.URL
 	Name    string
+
+	transport *http.Transport
 }
 
+// Protocols a backend can be declared to speak. HTTP/1.1 is the default, h2 is HTTP/2 over TLS and
+// h2c is cleartext HTTP/2 with prior knowledge.
+const (
+	ProtocolHTTP1 = "http/1.1"
+	ProtocolHTTP2 = "h2"
+	ProtocolH2C   = "h2c"
+)
+
 type BackendOptions struct {
-	Timeout time.Duration
+	// Timeout - The dialer timeout used when neither the ACL nor Timeouts set a connect timeout
+	Timeout   time.Duration
+	Timeouts  *TimeoutConfig
+	Rewrite   *Rewrite
+	TLSConfig *tls.Config
+	Protocol  string
 }
 
 func NewBackend(name string, serverURL string, options BackendOptions) (*Backend, error) {
@@ -27,29 +45,168 @@ func NewBackend(name string, serverURL string, options BackendOptions) (*Backend
 		return nil, errors.Wrapf(err, "URL Parsing failed for: %s", serverURL)
 	}
 
+	if err := validateProtocol(server, options.Protocol); err != nil {
+		return nil, err
+	}
+
+	proxy := newWeaverReverseProxy(server, options)
+
 	return &Backend{
-		Name:    name,
-		Handler: newWeaverReverseProxy(server, options),
-		Server:  server,
+		Name:      name,
+		Handler:   withTimeouts(withACLFlushInterval(proxy), options.Timeouts),
+		Server:    server,
+		transport: proxy.Transport.(*http.Transport),
 	}, nil
 }
 
+// CloseIdleConnections - Closes the idle connections to the backend, a replaced backend would
+// otherwise keep them open until they time out
+func (backend *Backend) CloseIdleConnections() {
+	if backend.transport != nil {
+		backend.transport.CloseIdleConnections()
+	}
+}
+
 func newWeaverReverseProxy(target *url.URL, options BackendOptions) *httputil.ReverseProxy {
 	proxyConfig := config.Proxy()
 
 	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		route, routed := RequestRouteFrom(req.Context())
+
+		rewrite := options.Rewrite
+		if rewrite == nil && routed && route.ACL.Endpoint != nil {
+			rewrite = route.ACL.Endpoint.rewrite
+		}
+
+		rewrite.Apply(req.URL)
+		director(req)
+
+		if routed && route.ACL.Headers != nil {
+			route.ACL.Headers.Request.apply(req.Header, route)
+		}
+	}
+
+	proxy.ModifyResponse = func(res *http.Response) error {
+		responseHeadersReceived(res.Request)
+
+		// the request ID weaver assigned is already set on the response, the backend's echo would repeat it
+		if requestid.FromContext(res.Request.Context()) != "" {
+			res.Header.Del(config.RequestID().Header())
+		}
+
+		if route, ok := RequestRouteFrom(res.Request.Context()); ok {
+			route.UpstreamStatus = res.StatusCode
+
+			if route.ACL.Headers != nil {
+				route.ACL.Headers.Response.apply(res.Header, route)
+			}
+
+			if err := route.Plugins.Response(res, route); err != nil {
+				route.PluginErr = err
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		if route, routed := RequestRouteFrom(req.Context()); routed {
+			if route.PluginErr == nil {
+				route.UpstreamErr = err
+			}
+			return
+		}
+
+		logger.Errorrf(req, "failed to proxy to backend %s: %s", target, err)
+
+		if IsGRPCRequest(req) {
+			WriteGRPCError(w, GRPCStatusUnavailable, "weaver:upstream:unavailable")
+			return
+		}
+
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	dialer := &timeoutDialer{
+		connectTimeout: options.Timeout,
+		keepAlive:      proxyConfig.ProxyDialerKeepAliveInMS(),
+		tlsConfig:      options.TLSConfig,
+		nextProtos:     tlsNextProtos(options.Protocol),
+	}
+
 	proxy.Transport = &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   options.Timeout,
-			KeepAlive: proxyConfig.ProxyDialerKeepAliveInMS(),
-			DualStack: true,
-		}).DialContext,
+		Proxy:          http.ProxyFromEnvironment,
+		DialContext:    dialer.DialContext,
+		DialTLSContext: dialer.DialTLSContext,
 
+		TLSClientConfig:   options.TLSConfig,
 		MaxIdleConns:      proxyConfig.ProxyMaxIdleConns(),
 		IdleConnTimeout:   proxyConfig.ProxyIdleConnTimeoutInMS(),
 		DisableKeepAlives: !proxyConfig.KeepAliveEnabled(),
+		Protocols:         transportProtocols(options.Protocol),
 	}
 
 	return proxy
 }
+
+// withACLFlushInterval serves the request with the flush interval of the routed ACL, a reverse proxy
+// is shared by every ACL pointing at the backend so a copy carries the ACL's interval
+func withACLFlushInterval(proxy *httputil.ReverseProxy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		route, routed := RequestRouteFrom(req.Context())
+		if !routed || route.ACL.FlushIntervalInMS == 0 {
+			proxy.ServeHTTP(w, req)
+			return
+		}
+
+		flushing := *proxy
+		flushing.FlushInterval = route.ACL.FlushInterval()
+		flushing.ServeHTTP(w, req)
+	})
+}
+
+func validateProtocol(server *url.URL, protocol string) error {
+	switch protocol {
+	case "", ProtocolHTTP1:
+		return nil
+	case ProtocolHTTP2:
+		if server.Scheme != "https" {
+			return fmt.Errorf("protocol %s needs an https backend: %s", protocol, server)
+		}
+	case ProtocolH2C:
+		if server.Scheme != "http" {
+			return fmt.Errorf("protocol %s needs an http backend: %s", protocol, server)
+		}
+	default:
+		return fmt.Errorf("unsupported backend protocol: %s", protocol)
+	}
+
+	return nil
+}
+
+func tlsNextProtos(protocol string) []string {
+	if protocol == ProtocolHTTP2 {
+		return []string{"h2"}
+	}
+
+	return []string{"http/1.1"}
+}
+
+func transportProtocols(protocol string) *http.Protocols {
+	protocols := &http.Protocols{}
+
+	switch protocol {
+	case ProtocolHTTP2:
+		protocols.SetHTTP2(true)
+	case ProtocolH2C:
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		protocols.SetHTTP1(true)
+	}
+
+	return protocols
+}
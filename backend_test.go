@@ -1,6 +1,10 @@
 package weaver
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,3 +31,89 @@ func TestNewBackendFailsWhenURLIsInvalid(t *testing.T) {
 
 	assert.Nil(t, backend)
 }
+
+func protoServer() *httptest.Server {
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func proxiedProto(t *testing.T, backend *Backend) string {
+	w := httptest.NewRecorder()
+	backend.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/drivers", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Header().Get("X-Proto")
+}
+
+func TestBackendSpeaksHTTP2OverTLS(t *testing.T) {
+	ts := protoServer()
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ts.Certificate())
+
+	backend, err := NewBackend("foobar", ts.URL, BackendOptions{
+		Protocol:  ProtocolHTTP2,
+		TLSConfig: &tls.Config{RootCAs: rootCAs},
+	})
+	require.NoError(t, err, "should not have failed to create new backend")
+
+	assert.Equal(t, "HTTP/2.0", proxiedProto(t, backend))
+}
+
+func TestBackendSpeaksH2C(t *testing.T) {
+	ts := protoServer()
+	ts.Config.Protocols = &http.Protocols{}
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	defer ts.Close()
+
+	backend, err := NewBackend("foobar", ts.URL, BackendOptions{Protocol: ProtocolH2C})
+	require.NoError(t, err, "should not have failed to create new backend")
+
+	assert.Equal(t, "HTTP/2.0", proxiedProto(t, backend))
+}
+
+func TestBackendDefaultsToHTTP1(t *testing.T) {
+	ts := protoServer()
+	ts.Start()
+	defer ts.Close()
+
+	backend, err := NewBackend("foobar", ts.URL, BackendOptions{})
+	require.NoError(t, err, "should not have failed to create new backend")
+
+	assert.Equal(t, "HTTP/1.1", proxiedProto(t, backend))
+}
+
+func TestNewBackendFailsWhenProtocolIsInvalid(t *testing.T) {
+	_, err := NewBackend("foobar", "http://localhost", BackendOptions{Protocol: "spdy"})
+	assert.EqualError(t, err, "unsupported backend protocol: spdy")
+
+	_, err = NewBackend("foobar", "http://localhost", BackendOptions{Protocol: ProtocolHTTP2})
+	assert.EqualError(t, err, "protocol h2 needs an https backend: http://localhost")
+
+	_, err = NewBackend("foobar", "https://localhost", BackendOptions{Protocol: ProtocolH2C})
+	assert.EqualError(t, err, "protocol h2c needs an http backend: https://localhost")
+}
+
+func TestBackendForwardsEscapedSlashesOfRewrittenPaths(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.EscapedPath())
+	}))
+	defer server.Close()
+
+	rewrite, err := NewRewrite(&RewriteConfig{StripPrefix: "/svc"}, "")
+	require.NoError(t, err)
+
+	backend, err := NewBackend("foobar", server.URL, BackendOptions{Rewrite: rewrite})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	backend.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/svc/files/a%2Fb", nil))
+
+	assert.Equal(t, "/files/a%2Fb", w.Header().Get("X-Path"))
+}
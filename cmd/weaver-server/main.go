@@ -6,13 +6,18 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 
 	raven "github.com/getsentry/raven-go"
+	"github.com/gojektech/weaver"
 	"github.com/gojektech/weaver/config"
 	"github.com/gojektech/weaver/etcd"
+	"github.com/gojektech/weaver/pkg/accesslog"
 	"github.com/gojektech/weaver/pkg/instrumentation"
 	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/gojektech/weaver/pkg/redact"
 	"github.com/gojektech/weaver/server"
 	cli "gopkg.in/urfave/cli.v1"
 )
@@ -29,6 +34,17 @@ func main() {
 			Description: "Start weaver server",
 			Action:      startWeaver,
 		},
+		{
+			Name:        "config",
+			Description: "Work with weaver's configuration",
+			Subcommands: []cli.Command{
+				{
+					Name:        "check",
+					Description: "Validate weaver.conf and the environment without starting weaver",
+					Action:      checkConfig,
+				},
+			},
+		},
 	}
 
 	app.Run(os.Args)
@@ -38,11 +54,17 @@ func startWeaver(_ *cli.Context) error {
 	sigC := make(chan os.Signal, 1)
 	signal.Notify(sigC, syscall.SIGINT, syscall.SIGKILL, syscall.SIGTERM)
 
-	config.Load()
+	if err := loadConfig(); err != nil {
+		return err
+	}
 
 	raven.SetDSN(config.SentryDSN())
 	logger.SetupLogger()
 
+	if err := accesslog.Setup(); err != nil {
+		log.Fatalf("StartServer: failed to set up access log: %s", err)
+	}
+
 	err := instrumentation.InitiateStatsDMetrics()
 	if err != nil {
 		log.Printf("StatsD: Error initiating client %s", err)
@@ -60,15 +82,83 @@ func startWeaver(_ *cli.Context) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	go server.StartServer(ctx, routeLoader)
 
+	var reloader sync.WaitGroup
+	reloader.Add(1)
+	go func() {
+		defer reloader.Done()
+		reloadOnHangup(ctx)
+	}()
+
 	sig := <-sigC
-	log.Printf("Received %d, shutting down", sig)
+	log.Printf("Received %s, shutting down", sig)
+
+	if err := server.ShutdownServer(context.Background()); err != nil {
+		log.Printf("ShutdownServer: in-flight requests did not complete: %s", err)
+	}
+
+	// route, certificate and config watchers, and a reload in progress, stop before statsd and New
+	// Relic are flushed
+	cancel()
+	server.WaitServer()
+	reloader.Wait()
 
-	defer cancel()
-	server.ShutdownServer(ctx)
+	return nil
+}
+
+// exitCodeInvalidConfig - The exit code when the configuration is invalid, EX_CONFIG of sysexits.h
+const exitCodeInvalidConfig = 78
+
+func checkConfig(_ *cli.Context) error {
+	if err := loadConfig(); err != nil {
+		return err
+	}
 
+	fmt.Println("config is valid")
 	return nil
 }
 
+// loadConfig loads the configuration and validates the settings parsed outside the config package,
+// reporting every problem found at once
+func loadConfig() error {
+	var problems []string
+	if invalid, ok := config.Load().(*config.ValidationError); ok {
+		problems = append(problems, invalid.Problems...)
+	}
+
+	if _, err := weaver.ParseErrorTemplates(config.ErrorTemplates()); err != nil {
+		problems = append(problems, fmt.Sprintf("key ERROR_TEMPLATES is invalid: %s", err))
+	}
+
+	if err := redact.Setup(); err != nil {
+		problems = append(problems, fmt.Sprintf("key REDACT_QUERY_PARAMS is invalid: %s", err))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return cli.NewExitError("invalid config:\n  "+strings.Join(problems, "\n  "), exitCodeInvalidConfig)
+}
+
+// reloadOnHangup reloads weaver.conf every time weaver receives SIGHUP
+func reloadOnHangup(ctx context.Context) {
+	hupC := make(chan os.Signal, 1)
+	signal.Notify(hupC, syscall.SIGHUP)
+	defer signal.Stop(hupC)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hupC:
+			log.Printf("Received SIGHUP, reloading config")
+			if err := server.ReloadConfig(ctx); err != nil {
+				log.Printf("ReloadConfig: %s", err)
+			}
+		}
+	}
+}
+
 // Build information (will be injected during build)
 var (
 	Version   = "1.0.0"
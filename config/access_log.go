@@ -0,0 +1,118 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+type AccessLogConfig struct {
+	enabled           bool
+	format            string
+	fields            []string
+	output            string
+	filePath          string
+	fileMaxSizeInMB   int
+	fileMaxBackups    int
+	syslogNetwork     string
+	syslogAddress     string
+	syslogTag         string
+	sampleRate        int
+	slowThresholdInMS int
+}
+
+func loadAccessLogConfig(v *validation) AccessLogConfig {
+	cfg := AccessLogConfig{
+		enabled:           viper.GetBool("ACCESS_LOG_ENABLED"),
+		format:            v.extractStringValue("ACCESS_LOG_FORMAT"),
+		output:            v.extractStringValue("ACCESS_LOG_OUTPUT"),
+		syslogNetwork:     viper.GetString("ACCESS_LOG_SYSLOG_NETWORK"),
+		syslogAddress:     viper.GetString("ACCESS_LOG_SYSLOG_ADDRESS"),
+		syslogTag:         v.extractStringValue("ACCESS_LOG_SYSLOG_TAG"),
+		sampleRate:        v.extractIntValue("ACCESS_LOG_SAMPLE_RATE"),
+		slowThresholdInMS: v.extractIntValue("ACCESS_LOG_SLOW_THRESHOLD_IN_MS"),
+	}
+
+	if fields := viper.GetString("ACCESS_LOG_FIELDS"); fields != "" {
+		for _, field := range strings.Split(fields, ",") {
+			cfg.fields = append(cfg.fields, strings.TrimSpace(field))
+		}
+	}
+
+	if cfg.output == "file" {
+		cfg.filePath = v.extractStringValue("ACCESS_LOG_FILE")
+		cfg.fileMaxSizeInMB = v.extractIntValue("ACCESS_LOG_FILE_MAX_SIZE_IN_MB")
+		cfg.fileMaxBackups = v.extractIntValue("ACCESS_LOG_FILE_MAX_BACKUPS")
+	}
+
+	switch cfg.format {
+	case "json", "common", "combined":
+	default:
+		v.invalid("ACCESS_LOG_FORMAT", "must be json, common or combined, got: %s", cfg.format)
+	}
+
+	switch cfg.output {
+	case "stdout", "file", "syslog":
+	default:
+		v.invalid("ACCESS_LOG_OUTPUT", "must be stdout, file or syslog, got: %s", cfg.output)
+	}
+
+	return cfg
+}
+
+// Enabled - Whether requests are access logged, regardless of LOGGER_LEVEL
+func (ac AccessLogConfig) Enabled() bool {
+	return ac.enabled
+}
+
+// Format - json, common (Common Log Format) or combined (Combined Log Format)
+func (ac AccessLogConfig) Format() string {
+	return ac.format
+}
+
+// Fields - The fields of json access logs, all but request headers when empty
+func (ac AccessLogConfig) Fields() []string {
+	return ac.fields
+}
+
+// Output - Where access logs are written: stdout, file or syslog
+func (ac AccessLogConfig) Output() string {
+	return ac.output
+}
+
+func (ac AccessLogConfig) FilePath() string {
+	return ac.filePath
+}
+
+// FileMaxSizeInBytes - The size at which the access log file is rotated
+func (ac AccessLogConfig) FileMaxSizeInBytes() int64 {
+	return int64(ac.fileMaxSizeInMB) * 1024 * 1024
+}
+
+// FileMaxBackups - How many rotated access log files are kept
+func (ac AccessLogConfig) FileMaxBackups() int {
+	return ac.fileMaxBackups
+}
+
+// SyslogNetwork - The network of the syslog server, the local syslog daemon is used when empty
+func (ac AccessLogConfig) SyslogNetwork() string {
+	return ac.syslogNetwork
+}
+
+func (ac AccessLogConfig) SyslogAddress() string {
+	return ac.syslogAddress
+}
+
+func (ac AccessLogConfig) SyslogTag() string {
+	return ac.syslogTag
+}
+
+// SampleRate - Successful requests are logged 1 in SampleRate, errors are always logged
+func (ac AccessLogConfig) SampleRate() int {
+	return ac.sampleRate
+}
+
+// SlowThresholdInMS - Requests slower than this are always logged, 0 turns it off
+func (ac AccessLogConfig) SlowThresholdInMS() int {
+	return ac.slowThresholdInMS
+}
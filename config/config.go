@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	etcd "github.com/coreos/etcd/client"
@@ -13,11 +14,38 @@ import (
 	"github.com/spf13/viper"
 )
 
-var appConfig Config
+// appConfig holds the *Config in use, replaced as a whole when the configuration is reloaded
+var appConfig atomic.Value
+
+var (
+	defaultsOnce sync.Once
+	defaults     *Config
+)
+
+func current() *Config {
+	if cfg, ok := appConfig.Load().(*Config); ok {
+		return cfg
+	}
+
+	return defaultConfig()
+}
+
+// defaultConfig is the configuration in use until Load, made of the defaults alone so weaver can be
+// embedded without weaver.conf
+func defaultConfig() *Config {
+	defaultsOnce.Do(func() {
+		setDefaults()
+		defaults, _ = loadConfig()
+	})
+
+	return defaults
+}
 
 type Config struct {
 	proxyHost       string
 	proxyPort       int
+	adminHost       string
+	adminPort       int
 	etcdKeyPrefix   string
 	loggerLevel     string
 	etcdEndpoints   []string
@@ -29,13 +57,25 @@ type Config struct {
 	serverReadTimeout  time.Duration
 	serverWriteTimeout time.Duration
 
-	proxyConfig ProxyConfig
+	proxyConfig         ProxyConfig
+	tlsConfig           TLSConfig
+	upstreamTLSProfiles map[string]UpstreamTLS
+	errorTemplates      string
+	requestIDConfig     RequestIDConfig
+	accessLogConfig     AccessLogConfig
+	redactionConfig     RedactionConfig
+
+	configReloadIntervalInMS int
+	shutdownDrainPeriodInMS  int
+	shutdownTimeoutInMS      int
+	readinessMinACLs         int
 }
 
-func Load() {
-	viper.SetDefault("LOGGER_LEVEL", "error")
-	viper.SetDefault("SERVER_PORT", "8080")
-	viper.SetDefault("PROXY_PORT", "8081")
+// Load - Reads weaver.conf and the environment. Missing optional settings fall back to their defaults,
+// every missing or malformed setting left is reported in a ValidationError; the configuration is
+// still loaded with their zero values.
+func Load() error {
+	setDefaults()
 
 	viper.SetConfigName("weaver.conf")
 
@@ -44,61 +84,159 @@ func Load() {
 	viper.AddConfigPath("../../")
 	viper.SetConfigType("yaml")
 
-	viper.ReadInConfig()
+	var problems []string
+	if err := viper.ReadInConfig(); err != nil {
+		if _, notFound := err.(viper.ConfigFileNotFoundError); !notFound {
+			problems = append(problems, fmt.Sprintf("failed to read config file: %s", err))
+		}
+	}
+
 	viper.AutomaticEnv()
 
-	appConfig = Config{
-		proxyHost:          extractStringValue("PROXY_HOST"),
-		proxyPort:          extractIntValue("PROXY_PORT"),
-		etcdKeyPrefix:      extractStringValue("ETCD_KEY_PREFIX"),
-		loggerLevel:        extractStringValue("LOGGER_LEVEL"),
-		etcdEndpoints:      strings.Split(extractStringValue("ETCD_ENDPOINTS"), ","),
-		etcdDialTimeout:    time.Duration(extractIntValue("ETCD_DIAL_TIMEOUT")),
-		statsDConfig:       loadStatsDConfig(),
-		newRelicConfig:     loadNewRelicConfig(),
-		proxyConfig:        loadProxyConfig(),
-		sentryDSN:          extractStringValue("SENTRY_DSN"),
-		serverReadTimeout:  time.Duration(extractIntValue("SERVER_READ_TIMEOUT")),
-		serverWriteTimeout: time.Duration(extractIntValue("SERVER_WRITE_TIMEOUT")),
+	cfg, err := loadConfig()
+	appConfig.Store(cfg)
+
+	if invalid, ok := err.(*ValidationError); ok {
+		problems = append(problems, invalid.Problems...)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
 	}
+
+	return nil
+}
+
+func setDefaults() {
+	viper.SetDefault("LOGGER_LEVEL", "error")
+	viper.SetDefault("SERVER_HOST", "")
+	viper.SetDefault("SERVER_PORT", "8080")
+	viper.SetDefault("SERVER_READ_TIMEOUT", "0")
+	viper.SetDefault("SERVER_WRITE_TIMEOUT", "0")
+	viper.SetDefault("PROXY_HOST", "")
+	viper.SetDefault("PROXY_PORT", "8081")
+	viper.SetDefault("PROXY_DIALER_TIMEOUT_IN_MS", "1000")
+	viper.SetDefault("PROXY_DIALER_KEEP_ALIVE_IN_MS", "30000")
+	viper.SetDefault("PROXY_MAX_IDLE_CONNS", "100")
+	viper.SetDefault("PROXY_IDLE_CONN_TIMEOUT_IN_MS", "90000")
+	viper.SetDefault("ETCD_KEY_PREFIX", "weaver")
+	viper.SetDefault("ETCD_DIAL_TIMEOUT", "5")
+	viper.SetDefault("STATSD_ENABLED", "false")
+	viper.SetDefault("STATSD_HOST", "127.0.0.1")
+	viper.SetDefault("STATSD_PORT", "8125")
+	viper.SetDefault("STATSD_PREFIX", "weaver")
+	viper.SetDefault("STATSD_FLUSH_PERIOD_IN_SECONDS", "10")
+	viper.SetDefault("NEW_RELIC_ENABLED", "false")
+	viper.SetDefault("SENTRY_DSN", "")
+	viper.SetDefault("PROXY_TLS_MIN_VERSION", "1.2")
+	viper.SetDefault("PROXY_TLS_CIPHER_SUITES", "")
+	viper.SetDefault("PROXY_TLS_RELOAD_INTERVAL_IN_MS", "60000")
+	viper.SetDefault("REQUEST_ID_HEADER", "X-Request-Id")
+	viper.SetDefault("REQUEST_ID_FORMAT", "uuid")
+	viper.SetDefault("ACCESS_LOG_ENABLED", "true")
+	viper.SetDefault("ACCESS_LOG_FORMAT", "json")
+	viper.SetDefault("ACCESS_LOG_OUTPUT", "stdout")
+	viper.SetDefault("ACCESS_LOG_FILE_MAX_SIZE_IN_MB", "100")
+	viper.SetDefault("ACCESS_LOG_FILE_MAX_BACKUPS", "5")
+	viper.SetDefault("ACCESS_LOG_SYSLOG_TAG", "weaver")
+	viper.SetDefault("ACCESS_LOG_SAMPLE_RATE", "1")
+	viper.SetDefault("ACCESS_LOG_SLOW_THRESHOLD_IN_MS", "0")
+	viper.SetDefault("CONFIG_RELOAD_INTERVAL_IN_MS", "0")
+	viper.SetDefault("SHUTDOWN_DRAIN_PERIOD_IN_MS", "5000")
+	viper.SetDefault("SHUTDOWN_TIMEOUT_IN_MS", "30000")
+	viper.SetDefault("READINESS_MIN_ACLS", "0")
+	viper.SetDefault("REDACT_HEADERS", "Authorization,Proxy-Authorization,Cookie,Set-Cookie,X-Api-Key")
+}
+
+func loadConfig() (*Config, error) {
+	v := &validation{}
+
+	cfg := &Config{
+		proxyHost:                v.extractStringValue("PROXY_HOST"),
+		proxyPort:                v.extractPortValue("PROXY_PORT"),
+		adminHost:                v.extractStringValue("SERVER_HOST"),
+		adminPort:                v.extractPortValue("SERVER_PORT"),
+		etcdKeyPrefix:            v.extractStringValue("ETCD_KEY_PREFIX"),
+		loggerLevel:              v.extractStringValue("LOGGER_LEVEL"),
+		etcdEndpoints:            strings.Split(v.extractStringValue("ETCD_ENDPOINTS"), ","),
+		etcdDialTimeout:          time.Duration(v.extractIntValue("ETCD_DIAL_TIMEOUT")),
+		statsDConfig:             loadStatsDConfig(v),
+		newRelicConfig:           loadNewRelicConfig(v),
+		proxyConfig:              loadProxyConfig(v),
+		tlsConfig:                loadTLSConfig(v),
+		upstreamTLSProfiles:      loadUpstreamTLSProfiles(v),
+		errorTemplates:           viper.GetString("ERROR_TEMPLATES"),
+		requestIDConfig:          loadRequestIDConfig(v),
+		accessLogConfig:          loadAccessLogConfig(v),
+		redactionConfig:          loadRedactionConfig(),
+		configReloadIntervalInMS: v.extractIntValue("CONFIG_RELOAD_INTERVAL_IN_MS"),
+		shutdownDrainPeriodInMS:  v.extractIntValue("SHUTDOWN_DRAIN_PERIOD_IN_MS"),
+		shutdownTimeoutInMS:      v.extractIntValue("SHUTDOWN_TIMEOUT_IN_MS"),
+		readinessMinACLs:         v.extractIntValue("READINESS_MIN_ACLS"),
+		sentryDSN:                v.extractStringValue("SENTRY_DSN"),
+		serverReadTimeout:        time.Duration(v.extractIntValue("SERVER_READ_TIMEOUT")),
+		serverWriteTimeout:       time.Duration(v.extractIntValue("SERVER_WRITE_TIMEOUT")),
+	}
+
+	return cfg, v.err()
 }
 
 func ServerReadTimeoutInMillis() time.Duration {
-	return appConfig.serverReadTimeout * time.Millisecond
+	return current().serverReadTimeout * time.Millisecond
 }
 
 func ServerWriteTimeoutInMillis() time.Duration {
-	return appConfig.serverWriteTimeout * time.Millisecond
+	return current().serverWriteTimeout * time.Millisecond
 }
 
 func ProxyServerAddress() string {
-	return fmt.Sprintf("%s:%d", appConfig.proxyHost, appConfig.proxyPort)
+	return fmt.Sprintf("%s:%d", current().proxyHost, current().proxyPort)
+}
+
+func AdminServerAddress() string {
+	return fmt.Sprintf("%s:%d", current().adminHost, current().adminPort)
 }
 
 func ETCDKeyPrefix() string {
-	return appConfig.etcdKeyPrefix
+	return current().etcdKeyPrefix
 }
 
 func NewRelicConfig() newrelic.Config {
-	return appConfig.newRelicConfig
+	return current().newRelicConfig
 }
 
 func SentryDSN() string {
-	return appConfig.sentryDSN
+	return current().sentryDSN
 }
 
 func StatsD() StatsDConfig {
-	return appConfig.statsDConfig
+	return current().statsDConfig
 }
 
 func Proxy() ProxyConfig {
-	return appConfig.proxyConfig
+	return current().proxyConfig
+}
+
+func TLS() TLSConfig {
+	return current().tlsConfig
+}
+
+func RequestID() RequestIDConfig {
+	return current().requestIDConfig
+}
+
+func AccessLog() AccessLogConfig {
+	return current().accessLogConfig
+}
+
+func Redaction() RedactionConfig {
+	return current().redactionConfig
 }
 
 func NewETCDClient() (etcd.Client, error) {
 	return etcd.New(etcd.Config{
-		Endpoints:               appConfig.etcdEndpoints,
-		HeaderTimeoutPerRequest: appConfig.etcdDialTimeout * time.Second,
+		Endpoints:               current().etcdEndpoints,
+		HeaderTimeoutPerRequest: current().etcdDialTimeout * time.Second,
 		Transport: &http.Transport{
 			Proxy: http.ProxyFromEnvironment,
 			Dial: (&net.Dialer{
@@ -110,40 +248,36 @@ func NewETCDClient() (etcd.Client, error) {
 	})
 }
 
-func LogLevel() string {
-	return appConfig.loggerLevel
+// ErrorTemplates - The JSON object of default error templates by error code, applied to every ACL
+func ErrorTemplates() string {
+	return current().errorTemplates
 }
 
-func extractStringValue(key string) string {
-	checkPresenceOf(key)
-	return viper.GetString(key)
+// ConfigReloadInterval - How often weaver.conf is checked for changes to reload, 0 when it is not watched
+func ConfigReloadInterval() time.Duration {
+	return time.Duration(current().configReloadIntervalInMS) * time.Millisecond
 }
 
-func extractBoolValue(key string) bool {
-	checkPresenceOf(key)
-	return viper.GetBool(key)
+// ShutdownDrainPeriod - How long weaver keeps serving with failing readiness before it stops accepting connections
+func ShutdownDrainPeriod() time.Duration {
+	return time.Duration(current().shutdownDrainPeriodInMS) * time.Millisecond
 }
 
-func extractBoolValueDefaultToFalse(key string) bool {
-	if !viper.IsSet(key) {
-		return false
-	}
-
-	return viper.GetBool(key)
+// ShutdownTimeout - How long in-flight requests are given to complete once weaver stops accepting connections
+func ShutdownTimeout() time.Duration {
+	return time.Duration(current().shutdownTimeoutInMS) * time.Millisecond
 }
 
-func extractIntValue(key string) int {
-	checkPresenceOf(key)
-	v, err := strconv.Atoi(viper.GetString(key))
-	if err != nil {
-		panic(fmt.Sprintf("key %s is not a valid Integer value", key))
-	}
+// ReadinessMinACLs - How many ACLs must be loaded for weaver to report ready
+func ReadinessMinACLs() int {
+	return current().readinessMinACLs
+}
 
-	return v
+// ConfigFile - The path of the config file in use, empty when configured from the environment only
+func ConfigFile() string {
+	return viper.ConfigFileUsed()
 }
 
-func checkPresenceOf(key string) {
-	if !viper.IsSet(key) {
-		panic(fmt.Sprintf("key %s is not set", key))
-	}
+func LogLevel() string {
+	return current().loggerLevel
 }
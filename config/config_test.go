@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
 	"os"
 	"testing"
@@ -14,10 +15,10 @@ func TestShouldLoadConfigFromFile(t *testing.T) {
 	Load()
 
 	assert.NotEmpty(t, LogLevel())
-	assert.NotNil(t, loadStatsDConfig().Prefix())
-	assert.NotNil(t, loadStatsDConfig().FlushPeriodInSeconds())
-	assert.NotNil(t, loadStatsDConfig().Port())
-	assert.NotNil(t, loadStatsDConfig().Enabled())
+	assert.NotNil(t, loadStatsDConfig(&validation{}).Prefix())
+	assert.NotNil(t, loadStatsDConfig(&validation{}).FlushPeriodInSeconds())
+	assert.NotNil(t, loadStatsDConfig(&validation{}).Port())
+	assert.NotNil(t, loadStatsDConfig(&validation{}).Enabled())
 }
 
 func TestShouldLoadFromEnvVars(t *testing.T) {
@@ -48,6 +49,12 @@ func TestShouldLoadFromEnvVars(t *testing.T) {
 		require.NoError(t, err, fmt.Sprintf("failed to set env for %s key", k))
 	}
 
+	defer func() {
+		for k := range configVars {
+			os.Unsetenv(k)
+		}
+	}()
+
 	Load()
 
 	expectedStatsDConfig := StatsDConfig{
@@ -60,11 +67,11 @@ func TestShouldLoadFromEnvVars(t *testing.T) {
 
 	assert.Equal(t, "info", LogLevel())
 
-	assert.Equal(t, "newrelic", loadNewRelicConfig().AppName)
-	assert.Equal(t, "licence", loadNewRelicConfig().License)
-	assert.True(t, loadNewRelicConfig().Enabled)
+	assert.Equal(t, "newrelic", loadNewRelicConfig(&validation{}).AppName)
+	assert.Equal(t, "licence", loadNewRelicConfig(&validation{}).License)
+	assert.True(t, loadNewRelicConfig(&validation{}).Enabled)
 
-	assert.Equal(t, expectedStatsDConfig, loadStatsDConfig())
+	assert.Equal(t, expectedStatsDConfig, loadStatsDConfig(&validation{}))
 	assert.Equal(t, "weaver", ETCDKeyPrefix())
 	assert.Equal(t, "dsn", SentryDSN())
 
@@ -76,3 +83,125 @@ func TestShouldLoadFromEnvVars(t *testing.T) {
 	assert.Equal(t, time.Duration(100)*time.Millisecond, ServerReadTimeoutInMillis())
 	assert.Equal(t, time.Duration(100)*time.Millisecond, ServerWriteTimeoutInMillis())
 }
+
+func TestShouldLoadTLSConfigFromEnvVars(t *testing.T) {
+	configVars := map[string]string{
+		"PROXY_TLS_ENABLED":               "true",
+		"PROXY_TLS_CERTIFICATES":          "/tls/a.crt:/tls/a.key, /tls/b.crt:/tls/b.key",
+		"PROXY_TLS_MIN_VERSION":           "1.3",
+		"PROXY_TLS_CIPHER_SUITES":         "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+		"PROXY_TLS_RELOAD_INTERVAL_IN_MS": "500",
+	}
+
+	for k, v := range configVars {
+		err := os.Setenv(k, v)
+		require.NoError(t, err, fmt.Sprintf("failed to set env for %s key", k))
+	}
+
+	defer func() {
+		for k := range configVars {
+			os.Unsetenv(k)
+		}
+	}()
+
+	Load()
+
+	expectedCertificates := []CertificatePair{
+		{CertFile: "/tls/a.crt", KeyFile: "/tls/a.key"},
+		{CertFile: "/tls/b.crt", KeyFile: "/tls/b.key"},
+	}
+
+	assert.True(t, TLS().Enabled())
+	assert.Equal(t, expectedCertificates, TLS().Certificates())
+	assert.Equal(t, uint16(tls.VersionTLS13), TLS().MinVersion())
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}, TLS().CipherSuites())
+	assert.Equal(t, 500*time.Millisecond, TLS().ReloadIntervalInMS())
+}
+
+func TestShouldReportInvalidTLSConfig(t *testing.T) {
+	v := &validation{}
+
+	parseCertificatePairs(v, "/tls/a.crt")
+	parseTLSVersion(v, "1.4")
+	parseCipherSuites(v, "TLS_RSA_WITH_RC4_128_SHA")
+	assert.Nil(t, parseCipherSuites(v, ""))
+
+	assert.Equal(t, []string{
+		"key PROXY_TLS_CERTIFICATES has an invalid cert_file:key_file pair: /tls/a.crt",
+		"key PROXY_TLS_MIN_VERSION is not a valid TLS version: 1.4",
+		"key PROXY_TLS_CIPHER_SUITES has an unknown or insecure cipher suite: TLS_RSA_WITH_RC4_128_SHA",
+	}, v.problems)
+}
+
+func TestShouldLoadRequestIDConfig(t *testing.T) {
+	Load()
+
+	assert.Equal(t, "X-Request-Id", RequestID().Header())
+	assert.Equal(t, "uuid", RequestID().Format())
+	assert.False(t, RequestID().TrustIncoming())
+
+	os.Setenv("REQUEST_ID_HEADER", "x-correlation-id")
+	os.Setenv("REQUEST_ID_FORMAT", "ulid")
+	os.Setenv("REQUEST_ID_TRUST_INCOMING", "true")
+	defer func() {
+		os.Unsetenv("REQUEST_ID_HEADER")
+		os.Unsetenv("REQUEST_ID_FORMAT")
+		os.Unsetenv("REQUEST_ID_TRUST_INCOMING")
+		Load()
+	}()
+
+	Load()
+
+	assert.Equal(t, "X-Correlation-Id", RequestID().Header())
+	assert.Equal(t, "ulid", RequestID().Format())
+	assert.True(t, RequestID().TrustIncoming())
+
+	os.Setenv("REQUEST_ID_FORMAT", "snowflake")
+	assert.EqualError(t, Load(), "invalid config: key REQUEST_ID_FORMAT must be uuid or ulid, got: snowflake")
+}
+
+func TestShouldLoadAccessLogConfig(t *testing.T) {
+	Load()
+
+	assert.True(t, AccessLog().Enabled())
+	assert.Equal(t, "json", AccessLog().Format())
+	assert.Equal(t, "stdout", AccessLog().Output())
+	assert.Empty(t, AccessLog().Fields())
+	assert.Equal(t, 1, AccessLog().SampleRate())
+	assert.Equal(t, 0, AccessLog().SlowThresholdInMS())
+
+	os.Setenv("ACCESS_LOG_OUTPUT", "file")
+	os.Setenv("ACCESS_LOG_FILE", "/var/log/weaver/access.log")
+	os.Setenv("ACCESS_LOG_FIELDS", "status, latency_ms")
+	defer func() {
+		os.Unsetenv("ACCESS_LOG_OUTPUT")
+		os.Unsetenv("ACCESS_LOG_FILE")
+		os.Unsetenv("ACCESS_LOG_FIELDS")
+		os.Unsetenv("ACCESS_LOG_FORMAT")
+		Load()
+	}()
+
+	Load()
+
+	assert.Equal(t, "/var/log/weaver/access.log", AccessLog().FilePath())
+	assert.Equal(t, int64(100*1024*1024), AccessLog().FileMaxSizeInBytes())
+	assert.Equal(t, 5, AccessLog().FileMaxBackups())
+	assert.Equal(t, []string{"status", "latency_ms"}, AccessLog().Fields())
+
+	os.Setenv("ACCESS_LOG_FORMAT", "apache")
+	assert.EqualError(t, Load(), "invalid config: key ACCESS_LOG_FORMAT must be json, common or combined, got: apache")
+}
+
+func TestShouldLoadRedactionConfig(t *testing.T) {
+	os.Setenv("REDACT_QUERY_PARAMS", "^phone$, token")
+	defer func() {
+		os.Unsetenv("REDACT_QUERY_PARAMS")
+		Load()
+	}()
+
+	Load()
+
+	assert.Equal(t, []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}, Redaction().DenyHeaders())
+	assert.Empty(t, Redaction().AllowHeaders())
+	assert.Equal(t, []string{"^phone$", "token"}, Redaction().QueryParams())
+}
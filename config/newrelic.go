@@ -2,11 +2,21 @@ package config
 
 import (
 	newrelic "github.com/newrelic/go-agent"
+	"github.com/spf13/viper"
 )
 
-func loadNewRelicConfig() newrelic.Config {
-	config := newrelic.NewConfig(extractStringValue("NEW_RELIC_APP_NAME"),
-		extractStringValue("NEW_RELIC_LICENSE_KEY"))
-	config.Enabled = extractBoolValue("NEW_RELIC_ENABLED")
+func loadNewRelicConfig(v *validation) newrelic.Config {
+	if !v.extractBoolValue("NEW_RELIC_ENABLED") {
+		config := newrelic.NewConfig(viper.GetString("NEW_RELIC_APP_NAME"), viper.GetString("NEW_RELIC_LICENSE_KEY"))
+		config.Enabled = false
+		return config
+	}
+
+	config := newrelic.NewConfig(v.extractStringValue("NEW_RELIC_APP_NAME"),
+		v.extractStringValue("NEW_RELIC_LICENSE_KEY"))
+	if err := config.Validate(); err != nil {
+		v.invalid("NEW_RELIC_LICENSE_KEY", "is invalid: %s", err)
+	}
+
 	return config
 }
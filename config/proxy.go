@@ -8,15 +8,17 @@ type ProxyConfig struct {
 	proxyMaxIdleConns        int
 	proxyIdleConnTimeoutInMS int
 	keepAliveEnabled         bool
+	http2Enabled             bool
 }
 
-func loadProxyConfig() ProxyConfig {
+func loadProxyConfig(v *validation) ProxyConfig {
 	return ProxyConfig{
-		proxyDialerTimeoutInMS:   extractIntValue("PROXY_DIALER_TIMEOUT_IN_MS"),
-		proxyDialerKeepAliveInMS: extractIntValue("PROXY_DIALER_KEEP_ALIVE_IN_MS"),
-		proxyMaxIdleConns:        extractIntValue("PROXY_MAX_IDLE_CONNS"),
-		proxyIdleConnTimeoutInMS: extractIntValue("PROXY_IDLE_CONN_TIMEOUT_IN_MS"),
+		proxyDialerTimeoutInMS:   v.extractIntValue("PROXY_DIALER_TIMEOUT_IN_MS"),
+		proxyDialerKeepAliveInMS: v.extractIntValue("PROXY_DIALER_KEEP_ALIVE_IN_MS"),
+		proxyMaxIdleConns:        v.extractIntValue("PROXY_MAX_IDLE_CONNS"),
+		proxyIdleConnTimeoutInMS: v.extractIntValue("PROXY_IDLE_CONN_TIMEOUT_IN_MS"),
 		keepAliveEnabled:         extractBoolValueDefaultToFalse("PROXY_KEEP_ALIVE_ENABLED"),
+		http2Enabled:             extractBoolValueDefaultToFalse("PROXY_HTTP2_ENABLED"),
 	}
 }
 
@@ -39,3 +41,7 @@ func (pc ProxyConfig) ProxyIdleConnTimeoutInMS() time.Duration {
 func (pc ProxyConfig) KeepAliveEnabled() bool {
 	return pc.keepAliveEnabled
 }
+
+func (pc ProxyConfig) HTTP2Enabled() bool {
+	return pc.http2Enabled
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+type RedactionConfig struct {
+	denyHeaders  []string
+	allowHeaders []string
+	queryParams  []string
+}
+
+func loadRedactionConfig() RedactionConfig {
+	return RedactionConfig{
+		denyHeaders:  splitList(viper.GetString("REDACT_HEADERS")),
+		allowHeaders: splitList(viper.GetString("REDACT_ALLOW_HEADERS")),
+		queryParams:  splitList(viper.GetString("REDACT_QUERY_PARAMS")),
+	}
+}
+
+// DenyHeaders - Headers whose values are masked in logs
+func (rc RedactionConfig) DenyHeaders() []string {
+	return rc.denyHeaders
+}
+
+// AllowHeaders - When set, the only headers whose values are logged
+func (rc RedactionConfig) AllowHeaders() []string {
+	return rc.allowHeaders
+}
+
+// QueryParams - Regular expressions of query parameter names whose values are masked in logs
+func (rc RedactionConfig) QueryParams() []string {
+	return rc.queryParams
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
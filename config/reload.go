@@ -0,0 +1,100 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/viper"
+)
+
+// setting - A setting compared when reloading, apply is nil for settings that need a restart
+type setting struct {
+	key   string
+	value func(cfg *Config) interface{}
+	apply func(to, from *Config)
+}
+
+var settings = []setting{
+	{"LOGGER_LEVEL", func(cfg *Config) interface{} { return cfg.loggerLevel },
+		func(to, from *Config) { to.loggerLevel = from.loggerLevel }},
+	{"STATSD", func(cfg *Config) interface{} { return cfg.statsDConfig },
+		func(to, from *Config) { to.statsDConfig = from.statsDConfig }},
+	{"PROXY_DIALER_TIMEOUT_IN_MS", func(cfg *Config) interface{} { return cfg.proxyConfig.proxyDialerTimeoutInMS },
+		func(to, from *Config) {
+			to.proxyConfig.proxyDialerTimeoutInMS = from.proxyConfig.proxyDialerTimeoutInMS
+		}},
+	{"PROXY_DIALER_KEEP_ALIVE_IN_MS", func(cfg *Config) interface{} { return cfg.proxyConfig.proxyDialerKeepAliveInMS },
+		func(to, from *Config) {
+			to.proxyConfig.proxyDialerKeepAliveInMS = from.proxyConfig.proxyDialerKeepAliveInMS
+		}},
+	{"PROXY_MAX_IDLE_CONNS", func(cfg *Config) interface{} { return cfg.proxyConfig.proxyMaxIdleConns },
+		func(to, from *Config) { to.proxyConfig.proxyMaxIdleConns = from.proxyConfig.proxyMaxIdleConns }},
+	{"PROXY_IDLE_CONN_TIMEOUT_IN_MS", func(cfg *Config) interface{} { return cfg.proxyConfig.proxyIdleConnTimeoutInMS },
+		func(to, from *Config) {
+			to.proxyConfig.proxyIdleConnTimeoutInMS = from.proxyConfig.proxyIdleConnTimeoutInMS
+		}},
+	{"UPSTREAM_TLS_PROFILES", func(cfg *Config) interface{} { return cfg.upstreamTLSProfiles },
+		func(to, from *Config) { to.upstreamTLSProfiles = from.upstreamTLSProfiles }},
+	{"SHUTDOWN_DRAIN_PERIOD_IN_MS", func(cfg *Config) interface{} { return cfg.shutdownDrainPeriodInMS },
+		func(to, from *Config) { to.shutdownDrainPeriodInMS = from.shutdownDrainPeriodInMS }},
+	{"SHUTDOWN_TIMEOUT_IN_MS", func(cfg *Config) interface{} { return cfg.shutdownTimeoutInMS },
+		func(to, from *Config) { to.shutdownTimeoutInMS = from.shutdownTimeoutInMS }},
+
+	{"PROXY_HOST", func(cfg *Config) interface{} { return cfg.proxyHost }, nil},
+	{"PROXY_PORT", func(cfg *Config) interface{} { return cfg.proxyPort }, nil},
+	{"SERVER_HOST", func(cfg *Config) interface{} { return cfg.adminHost }, nil},
+	{"SERVER_PORT", func(cfg *Config) interface{} { return cfg.adminPort }, nil},
+	{"SERVER_READ_TIMEOUT", func(cfg *Config) interface{} { return cfg.serverReadTimeout }, nil},
+	{"SERVER_WRITE_TIMEOUT", func(cfg *Config) interface{} { return cfg.serverWriteTimeout }, nil},
+	{"PROXY_KEEP_ALIVE_ENABLED", func(cfg *Config) interface{} { return cfg.proxyConfig.keepAliveEnabled }, nil},
+	{"PROXY_HTTP2_ENABLED", func(cfg *Config) interface{} { return cfg.proxyConfig.http2Enabled }, nil},
+	{"PROXY_TLS", func(cfg *Config) interface{} { return cfg.tlsConfig }, nil},
+	{"ETCD", func(cfg *Config) interface{} {
+		return []interface{}{cfg.etcdKeyPrefix, cfg.etcdEndpoints, cfg.etcdDialTimeout}
+	}, nil},
+	{"NEW_RELIC", func(cfg *Config) interface{} { return cfg.newRelicConfig }, nil},
+	{"SENTRY_DSN", func(cfg *Config) interface{} { return cfg.sentryDSN }, nil},
+	{"ERROR_TEMPLATES", func(cfg *Config) interface{} { return cfg.errorTemplates }, nil},
+	{"REQUEST_ID", func(cfg *Config) interface{} { return cfg.requestIDConfig }, nil},
+	{"ACCESS_LOG", func(cfg *Config) interface{} { return cfg.accessLogConfig }, nil},
+	{"REDACT", func(cfg *Config) interface{} { return cfg.redactionConfig }, nil},
+	{"READINESS_MIN_ACLS", func(cfg *Config) interface{} { return cfg.readinessMinACLs }, nil},
+	{"CONFIG_RELOAD_INTERVAL_IN_MS", func(cfg *Config) interface{} { return cfg.configReloadIntervalInMS }, nil},
+}
+
+// Reload - Re-reads weaver.conf and the environment. Settings that can change at runtime are applied
+// and returned, settings that need a restart keep their current value and are returned as such.
+// Settings are named by key, or by key prefix for groups (e.g. STATSD). Nothing is applied when the
+// configuration is invalid.
+func Reload() (applied []string, restartRequired []string, err error) {
+	if err := viper.ReadInConfig(); err != nil {
+		if _, notFound := err.(viper.ConfigFileNotFoundError); !notFound {
+			return nil, nil, fmt.Errorf("failed to read config: %s", err)
+		}
+	}
+
+	loaded, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := current()
+	next := *cfg
+
+	for _, s := range settings {
+		if reflect.DeepEqual(s.value(cfg), s.value(loaded)) {
+			continue
+		}
+
+		if s.apply == nil {
+			restartRequired = append(restartRequired, s.key)
+			continue
+		}
+
+		s.apply(&next, loaded)
+		applied = append(applied, s.key)
+	}
+
+	appConfig.Store(&next)
+	return applied, restartRequired, nil
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadShouldApplyReloadableSettings(t *testing.T) {
+	Load()
+
+	os.Setenv("LOGGER_LEVEL", "warn")
+	os.Setenv("PROXY_MAX_IDLE_CONNS", "321")
+	defer os.Unsetenv("LOGGER_LEVEL")
+	defer os.Unsetenv("PROXY_MAX_IDLE_CONNS")
+
+	applied, restartRequired, err := Reload()
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"LOGGER_LEVEL", "PROXY_MAX_IDLE_CONNS"}, applied)
+	assert.Empty(t, restartRequired)
+	assert.Equal(t, "warn", LogLevel())
+	assert.Equal(t, 321, Proxy().ProxyMaxIdleConns())
+}
+
+func TestReloadShouldKeepSettingsThatRequireARestart(t *testing.T) {
+	Load()
+	address := ProxyServerAddress()
+
+	os.Setenv("PROXY_PORT", "18081")
+	defer os.Unsetenv("PROXY_PORT")
+
+	applied, restartRequired, err := Reload()
+	require.NoError(t, err)
+
+	assert.Empty(t, applied)
+	assert.Equal(t, []string{"PROXY_PORT"}, restartRequired)
+	assert.Equal(t, address, ProxyServerAddress())
+}
+
+func TestReloadShouldNotApplyInvalidConfig(t *testing.T) {
+	Load()
+	level := LogLevel()
+
+	os.Setenv("LOGGER_LEVEL", "warn")
+	os.Setenv("PROXY_MAX_IDLE_CONNS", "many")
+	defer os.Unsetenv("LOGGER_LEVEL")
+	defer os.Unsetenv("PROXY_MAX_IDLE_CONNS")
+
+	_, _, err := Reload()
+	require.Error(t, err)
+
+	assert.Equal(t, level, LogLevel())
+}
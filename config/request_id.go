@@ -0,0 +1,41 @@
+package config
+
+import (
+	"net/http"
+
+	"github.com/gojektech/weaver/pkg/requestid"
+)
+
+type RequestIDConfig struct {
+	header        string
+	trustIncoming bool
+	format        string
+}
+
+func loadRequestIDConfig(v *validation) RequestIDConfig {
+	format := v.extractStringValue("REQUEST_ID_FORMAT")
+	if !requestid.ValidFormat(format) {
+		v.invalid("REQUEST_ID_FORMAT", "must be %s or %s, got: %s", requestid.FormatUUID, requestid.FormatULID, format)
+	}
+
+	return RequestIDConfig{
+		header:        http.CanonicalHeaderKey(v.extractStringValue("REQUEST_ID_HEADER")),
+		trustIncoming: extractBoolValueDefaultToFalse("REQUEST_ID_TRUST_INCOMING"),
+		format:        format,
+	}
+}
+
+// Header - The header carrying the request ID to the backend and back to the client
+func (rc RequestIDConfig) Header() string {
+	return rc.header
+}
+
+// TrustIncoming - Whether a request ID sent by the client is kept instead of generating one
+func (rc RequestIDConfig) TrustIncoming() bool {
+	return rc.trustIncoming
+}
+
+// Format - The format of generated request IDs, uuid or ulid
+func (rc RequestIDConfig) Format() string {
+	return rc.format
+}
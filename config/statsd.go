@@ -8,13 +8,17 @@ type StatsDConfig struct {
 	enabled              bool
 }
 
-func loadStatsDConfig() StatsDConfig {
+func loadStatsDConfig(v *validation) StatsDConfig {
+	if !v.extractBoolValue("STATSD_ENABLED") {
+		return StatsDConfig{}
+	}
+
 	return StatsDConfig{
-		prefix:               extractStringValue("STATSD_PREFIX"),
-		flushPeriodInSeconds: extractIntValue("STATSD_FLUSH_PERIOD_IN_SECONDS"),
-		host:                 extractStringValue("STATSD_HOST"),
-		port:                 extractIntValue("STATSD_PORT"),
-		enabled:              extractBoolValue("STATSD_ENABLED"),
+		prefix:               v.extractStringValue("STATSD_PREFIX"),
+		flushPeriodInSeconds: v.extractIntValue("STATSD_FLUSH_PERIOD_IN_SECONDS"),
+		host:                 v.extractStringValue("STATSD_HOST"),
+		port:                 v.extractPortValue("STATSD_PORT"),
+		enabled:              true,
 	}
 }
 
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"crypto/tls"
+	"strings"
+	"time"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+type CertificatePair struct {
+	CertFile string
+	KeyFile  string
+}
+
+type TLSConfig struct {
+	enabled            bool
+	certificates       []CertificatePair
+	minVersion         uint16
+	cipherSuites       []uint16
+	reloadIntervalInMS int
+}
+
+func loadTLSConfig(v *validation) TLSConfig {
+	if !extractBoolValueDefaultToFalse("PROXY_TLS_ENABLED") {
+		return TLSConfig{}
+	}
+
+	return TLSConfig{
+		enabled:            true,
+		certificates:       parseCertificatePairs(v, v.extractStringValue("PROXY_TLS_CERTIFICATES")),
+		minVersion:         parseTLSVersion(v, v.extractStringValue("PROXY_TLS_MIN_VERSION")),
+		cipherSuites:       parseCipherSuites(v, v.extractStringValue("PROXY_TLS_CIPHER_SUITES")),
+		reloadIntervalInMS: v.extractIntValue("PROXY_TLS_RELOAD_INTERVAL_IN_MS"),
+	}
+}
+
+// parseCertificatePairs reads a comma separated list of cert_file:key_file pairs
+func parseCertificatePairs(v *validation, value string) []CertificatePair {
+	var pairs []CertificatePair
+
+	for _, pair := range strings.Split(value, ",") {
+		files := strings.Split(strings.TrimSpace(pair), ":")
+		if len(files) != 2 || files[0] == "" || files[1] == "" {
+			v.invalid("PROXY_TLS_CERTIFICATES", "has an invalid cert_file:key_file pair: %s", pair)
+			continue
+		}
+
+		pairs = append(pairs, CertificatePair{CertFile: files[0], KeyFile: files[1]})
+	}
+
+	return pairs
+}
+
+func parseTLSVersion(v *validation, value string) uint16 {
+	version, found := tlsVersions[value]
+	if !found {
+		v.invalid("PROXY_TLS_MIN_VERSION", "is not a valid TLS version: %s", value)
+	}
+
+	return version
+}
+
+// parseCipherSuites maps comma separated IANA cipher suite names; an empty value keeps Go's defaults
+func parseCipherSuites(v *validation, value string) []uint16 {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	available := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		available[suite.Name] = suite.ID
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(value, ",") {
+		id, found := available[strings.TrimSpace(name)]
+		if !found {
+			v.invalid("PROXY_TLS_CIPHER_SUITES", "has an unknown or insecure cipher suite: %s", name)
+			continue
+		}
+
+		suites = append(suites, id)
+	}
+
+	return suites
+}
+
+func (tc TLSConfig) Enabled() bool {
+	return tc.enabled
+}
+
+func (tc TLSConfig) Certificates() []CertificatePair {
+	return tc.certificates
+}
+
+func (tc TLSConfig) MinVersion() uint16 {
+	return tc.minVersion
+}
+
+func (tc TLSConfig) CipherSuites() []uint16 {
+	return tc.cipherSuites
+}
+
+func (tc TLSConfig) ReloadIntervalInMS() time.Duration {
+	return time.Duration(tc.reloadIntervalInMS) * time.Millisecond
+}
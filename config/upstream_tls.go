@@ -0,0 +1,120 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// UpstreamTLS - TLS settings for connections to a backend, either inline in a backend definition or
+// shared as a named profile in UPSTREAM_TLS_PROFILES. InsecureSkipVerify is a pointer so a backend
+// can set it to false over a profile setting it to true.
+type UpstreamTLS struct {
+	Profile            string `json:"profile,omitempty"`
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	CAFile             string `json:"ca_file,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+	InsecureSkipVerify *bool  `json:"insecure_skip_verify,omitempty"`
+}
+
+// loadUpstreamTLSProfiles reads UPSTREAM_TLS_PROFILES, a JSON object of profile name to UpstreamTLS
+func loadUpstreamTLSProfiles(v *validation) map[string]UpstreamTLS {
+	profiles := map[string]UpstreamTLS{}
+
+	value := viper.GetString("UPSTREAM_TLS_PROFILES")
+	if value == "" {
+		return profiles
+	}
+
+	if err := json.Unmarshal([]byte(value), &profiles); err != nil {
+		v.invalid("UPSTREAM_TLS_PROFILES", "is not a valid JSON object of profiles: %s", err)
+		return map[string]UpstreamTLS{}
+	}
+
+	return profiles
+}
+
+func UpstreamTLSProfile(name string) (UpstreamTLS, bool) {
+	profile, found := current().upstreamTLSProfiles[name]
+	return profile, found
+}
+
+// Resolve - Fills in settings from the named profile, settings set inline take precedence
+func (ut UpstreamTLS) Resolve() (UpstreamTLS, error) {
+	if ut.Profile == "" {
+		return ut, nil
+	}
+
+	profile, found := UpstreamTLSProfile(ut.Profile)
+	if !found {
+		return ut, fmt.Errorf("unknown upstream TLS profile: %s", ut.Profile)
+	}
+
+	if ut.CertFile != "" || ut.KeyFile != "" {
+		profile.CertFile, profile.KeyFile = ut.CertFile, ut.KeyFile
+	}
+
+	if ut.CAFile != "" {
+		profile.CAFile = ut.CAFile
+	}
+
+	if ut.ServerName != "" {
+		profile.ServerName = ut.ServerName
+	}
+
+	if ut.InsecureSkipVerify != nil {
+		profile.InsecureSkipVerify = ut.InsecureSkipVerify
+	}
+
+	profile.Profile = ut.Profile
+
+	return profile, nil
+}
+
+// ClientConfig - Builds the tls.Config used by a backend's transport
+func (ut UpstreamTLS) ClientConfig() (*tls.Config, error) {
+	resolved, err := ut.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         resolved.ServerName,
+		InsecureSkipVerify: resolved.InsecureSkipVerify != nil && *resolved.InsecureSkipVerify,
+	}
+
+	if (resolved.CertFile == "") != (resolved.KeyFile == "") {
+		return nil, errors.New("both cert_file and key_file are needed for a client certificate")
+	}
+
+	if resolved.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(resolved.CertFile, resolved.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load client certificate %s", resolved.CertFile)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if resolved.CAFile != "" {
+		caBundle, err := ioutil.ReadFile(resolved.CAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read CA bundle %s", resolved.CAFile)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", resolved.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
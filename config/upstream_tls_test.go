@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldLoadUpstreamTLSProfilesFromEnvVars(t *testing.T) {
+	require.NoError(t, os.Setenv("UPSTREAM_TLS_PROFILES", `{
+		"shard-mtls": { "cert_file": "/tls/client.crt", "key_file": "/tls/client.key", "ca_file": "/tls/ca.crt" }
+	}`))
+	defer os.Unsetenv("UPSTREAM_TLS_PROFILES")
+
+	Load()
+
+	profile, found := UpstreamTLSProfile("shard-mtls")
+	require.True(t, found, "should have found the shard-mtls profile")
+	assert.Equal(t, UpstreamTLS{CertFile: "/tls/client.crt", KeyFile: "/tls/client.key", CAFile: "/tls/ca.crt"}, profile)
+
+	resolved, err := UpstreamTLS{Profile: "shard-mtls", ServerName: "drivers.internal"}.Resolve()
+	require.NoError(t, err, "should not have failed to resolve the profile")
+
+	assert.Equal(t, UpstreamTLS{
+		Profile:    "shard-mtls",
+		CertFile:   "/tls/client.crt",
+		KeyFile:    "/tls/client.key",
+		CAFile:     "/tls/ca.crt",
+		ServerName: "drivers.internal",
+	}, resolved)
+
+	_, err = UpstreamTLS{Profile: "unknown"}.Resolve()
+	assert.EqualError(t, err, "unknown upstream TLS profile: unknown")
+}
+
+func TestUpstreamTLSClientConfig(t *testing.T) {
+	insecure := true
+	tlsConfig, err := UpstreamTLS{ServerName: "drivers.internal", InsecureSkipVerify: &insecure}.ClientConfig()
+	require.NoError(t, err, "should not have failed to build the TLS config")
+
+	assert.Equal(t, "drivers.internal", tlsConfig.ServerName)
+	assert.True(t, tlsConfig.InsecureSkipVerify)
+	assert.Nil(t, tlsConfig.RootCAs)
+
+	_, err = UpstreamTLS{CertFile: "/tls/client.crt"}.ClientConfig()
+	assert.EqualError(t, err, "both cert_file and key_file are needed for a client certificate")
+
+	_, err = UpstreamTLS{CAFile: "/nope/ca.crt"}.ClientConfig()
+	assert.Error(t, err, "should have failed to read a missing CA bundle")
+}
+
+func TestInlineInsecureSkipVerifyOverridesTheProfile(t *testing.T) {
+	require.NoError(t, os.Setenv("UPSTREAM_TLS_PROFILES", `{"dev": {"insecure_skip_verify": true}}`))
+	defer os.Unsetenv("UPSTREAM_TLS_PROFILES")
+
+	Load()
+
+	var backend UpstreamTLS
+	require.NoError(t, json.Unmarshal([]byte(`{"profile": "dev", "insecure_skip_verify": false}`), &backend))
+
+	tlsConfig, err := backend.ClientConfig()
+	require.NoError(t, err)
+	assert.False(t, tlsConfig.InsecureSkipVerify, "should have kept the backend's setting")
+
+	tlsConfig, err = UpstreamTLS{Profile: "dev"}.ClientConfig()
+	require.NoError(t, err)
+	assert.True(t, tlsConfig.InsecureSkipVerify, "should have used the profile's setting")
+}
+
+func TestShouldReportInvalidUpstreamTLSProfiles(t *testing.T) {
+	require.NoError(t, os.Setenv("UPSTREAM_TLS_PROFILES", `["shard-mtls"]`))
+	defer os.Unsetenv("UPSTREAM_TLS_PROFILES")
+
+	v := &validation{}
+	assert.Empty(t, loadUpstreamTLSProfiles(v))
+	require.Len(t, v.problems, 1)
+	assert.Contains(t, v.problems[0], "key UPSTREAM_TLS_PROFILES is not a valid JSON object of profiles")
+}
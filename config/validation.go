@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ValidationError - Every missing or malformed setting found loading the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (ve *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config: %s", strings.Join(ve.Problems, "; "))
+}
+
+// validation collects the problems of the settings read while loading the configuration, so they
+// are all reported at once instead of failing on the first one
+type validation struct {
+	problems []string
+}
+
+func (v *validation) invalid(key, format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf("key %s %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validation) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Problems: v.problems}
+}
+
+func (v *validation) extractStringValue(key string) string {
+	v.checkPresenceOf(key)
+	return viper.GetString(key)
+}
+
+func (v *validation) extractBoolValue(key string) bool {
+	v.checkPresenceOf(key)
+
+	value, err := strconv.ParseBool(viper.GetString(key))
+	if err != nil && viper.IsSet(key) {
+		v.invalid(key, "is not a valid Boolean value: %s", viper.GetString(key))
+	}
+
+	return value
+}
+
+func (v *validation) extractIntValue(key string) int {
+	if !v.checkPresenceOf(key) {
+		return 0
+	}
+
+	value, err := strconv.Atoi(viper.GetString(key))
+	if err != nil {
+		v.invalid(key, "is not a valid Integer value: %s", viper.GetString(key))
+	}
+
+	return value
+}
+
+func (v *validation) extractPortValue(key string) int {
+	port := v.extractIntValue(key)
+	if port < 0 || port > 65535 {
+		v.invalid(key, "is not a valid port: %d", port)
+	}
+
+	return port
+}
+
+func (v *validation) checkPresenceOf(key string) bool {
+	if !viper.IsSet(key) {
+		v.invalid(key, "is not set")
+		return false
+	}
+
+	return true
+}
+
+func extractBoolValueDefaultToFalse(key string) bool {
+	if !viper.IsSet(key) {
+		return false
+	}
+
+	return viper.GetBool(key)
+}
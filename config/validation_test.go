@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadShouldReportEveryInvalidSetting(t *testing.T) {
+	os.Setenv("PROXY_PORT", "eighty")
+	os.Setenv("STATSD_ENABLED", "true")
+	os.Setenv("STATSD_PORT", "99999")
+	defer func() {
+		os.Unsetenv("PROXY_PORT")
+		os.Unsetenv("STATSD_ENABLED")
+		os.Unsetenv("STATSD_PORT")
+		Load()
+	}()
+
+	err := Load()
+	require.Error(t, err)
+
+	invalid, ok := err.(*ValidationError)
+	require.True(t, ok)
+	assert.Equal(t, []string{
+		"key PROXY_PORT is not a valid Integer value: eighty",
+		"key STATSD_PORT is not a valid port: 99999",
+	}, invalid.Problems)
+}
+
+func TestLoadShouldNotRequireSettingsOfDisabledFeatures(t *testing.T) {
+	os.Setenv("STATSD_ENABLED", "false")
+	os.Setenv("STATSD_PORT", "")
+	os.Setenv("NEW_RELIC_ENABLED", "false")
+	os.Setenv("NEW_RELIC_LICENSE_KEY", "")
+	defer func() {
+		os.Unsetenv("STATSD_ENABLED")
+		os.Unsetenv("STATSD_PORT")
+		os.Unsetenv("NEW_RELIC_ENABLED")
+		os.Unsetenv("NEW_RELIC_LICENSE_KEY")
+		Load()
+	}()
+
+	require.NoError(t, Load())
+	assert.False(t, StatsD().Enabled())
+	assert.False(t, NewRelicConfig().Enabled)
+}
+
+func TestValidationShouldReportMissingSettings(t *testing.T) {
+	v := &validation{}
+
+	assert.Equal(t, 0, v.extractIntValue("WEAVER_TEST_MISSING_INT"))
+	assert.Equal(t, "", v.extractStringValue("WEAVER_TEST_MISSING_STRING"))
+
+	assert.EqualError(t, v.err(), "invalid config: key WEAVER_TEST_MISSING_INT is not set; key WEAVER_TEST_MISSING_STRING is not set")
+}
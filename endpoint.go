@@ -2,7 +2,6 @@ package weaver
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/gojektech/weaver/pkg/matcher"
@@ -15,22 +14,26 @@ type EndpointConfig struct {
 	ShardExpr   string          `json:"shard_expr"`
 	ShardFunc   string          `json:"shard_func"`
 	ShardConfig json.RawMessage `json:"shard_config"`
+
+	MaxBodyBytes int64          `json:"max_body_bytes,omitempty"`
+	Rewrite      *RewriteConfig `json:"rewrite,omitempty"`
 }
 
 func (endpointConfig *EndpointConfig) genShardKeyFunc() (shardKeyFunc, error) {
-	matcherFunc, found := matcher.New(endpointConfig.Matcher)
-	if !found {
-		return nil, errors.WithStack(fmt.Errorf("failed to find a matcherMux for: %s", endpointConfig.Matcher))
+	matcherFunc, err := matcher.New(endpointConfig.Matcher, endpointConfig.ShardExpr, matcher.Options{
+		MaxBodyBytes: endpointConfig.MaxBodyBytes,
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
 	}
 
-	return func(req *http.Request) (string, error) {
-		return matcherFunc(req, endpointConfig.ShardExpr)
-	}, nil
+	return shardKeyFunc(matcherFunc), nil
 }
 
 type Endpoint struct {
 	sharder      Sharder
 	shardKeyFunc shardKeyFunc
+	rewrite      *Rewrite
 }
 
 func NewEndpoint(endpointConfig *EndpointConfig, sharder Sharder) (*Endpoint, error) {
@@ -43,19 +46,44 @@ func NewEndpoint(endpointConfig *EndpointConfig, sharder Sharder) (*Endpoint, er
 		return nil, errors.Wrapf(err, "failed to generate shardKeyFunc for %s", endpointConfig.ShardExpr)
 	}
 
+	var pathExpr string
+	if endpointConfig.Matcher == "path" {
+		pathExpr = endpointConfig.ShardExpr
+	}
+
+	rewrite, err := NewRewrite(endpointConfig.Rewrite, pathExpr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compile rewrite for %s", endpointConfig.ShardExpr)
+	}
+
 	return &Endpoint{
 		sharder:      sharder,
 		shardKeyFunc: shardKeyFunc,
+		rewrite:      rewrite,
 	}, nil
 }
 
-func (endpoint *Endpoint) Shard(request *http.Request) (*Backend, error) {
+func (endpoint *Endpoint) Shard(request *http.Request) (*Backend, string, error) {
 	shardKey, err := endpoint.shardKeyFunc(request)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to find shardKey")
+		return nil, "", errors.Wrapf(err, "failed to find shardKey")
+	}
+
+	backend, err := endpoint.sharder.Shard(shardKey)
+	return backend, shardKey, err
+}
+
+// CloseIdleConnections - Closes the idle connections of every backend of the endpoint once it is
+// replaced, sharders that cannot list their backends are left to their idle timeouts
+func (endpoint *Endpoint) CloseIdleConnections() {
+	lister, ok := endpoint.sharder.(BackendLister)
+	if !ok {
+		return
 	}
 
-	return endpoint.sharder.Shard(shardKey)
+	for _, backend := range lister.Backends() {
+		backend.CloseIdleConnections()
+	}
 }
 
 type shardKeyFunc func(*http.Request) (string, error)
@@ -11,7 +11,7 @@ import (
 func TestNewEndpoint(t *testing.T) {
 	endpointConfig := &EndpointConfig{
 		Matcher:     "path",
-		ShardExpr:   "/.*",
+		ShardExpr:   "/(.*)",
 		ShardFunc:   "lookup",
 		ShardConfig: json.RawMessage(`{}`),
 	}
@@ -26,7 +26,7 @@ func TestNewEndpoint(t *testing.T) {
 func TestNewEndpoint_SharderIsNil(t *testing.T) {
 	endpointConfig := &EndpointConfig{
 		Matcher:     "path",
-		ShardExpr:   "/.*",
+		ShardExpr:   "/(.*)",
 		ShardFunc:   "lookup",
 		ShardConfig: json.RawMessage(`{}`),
 	}
@@ -36,6 +36,46 @@ func TestNewEndpoint_SharderIsNil(t *testing.T) {
 	assert.Nil(t, endpoint)
 }
 
+func TestNewEndpoint_ShardExprIsInvalid(t *testing.T) {
+	endpointConfig := &EndpointConfig{
+		Matcher:     "path",
+		ShardExpr:   "/(.*",
+		ShardFunc:   "lookup",
+		ShardConfig: json.RawMessage(`{}`),
+	}
+
+	endpoint, err := NewEndpoint(endpointConfig, &stubSharder{})
+	assert.Error(t, err, "should fail to create an endpoint when shard expr does not compile")
+	assert.Nil(t, endpoint)
+}
+
+func TestNewEndpoint_MatcherIsUnknown(t *testing.T) {
+	endpointConfig := &EndpointConfig{
+		Matcher:     "cookie",
+		ShardExpr:   "session",
+		ShardFunc:   "lookup",
+		ShardConfig: json.RawMessage(`{}`),
+	}
+
+	endpoint, err := NewEndpoint(endpointConfig, &stubSharder{})
+	assert.Error(t, err, "should fail to create an endpoint when matcher is unknown")
+	assert.Nil(t, endpoint)
+}
+
+func TestNewEndpoint_RewriteIsInvalid(t *testing.T) {
+	endpointConfig := &EndpointConfig{
+		Matcher:     "path",
+		ShardExpr:   "/drivers/(\\d+)",
+		ShardFunc:   "lookup",
+		ShardConfig: json.RawMessage(`{}`),
+		Rewrite:     &RewriteConfig{Replacement: "/v2/drivers/$2"},
+	}
+
+	endpoint, err := NewEndpoint(endpointConfig, &stubSharder{})
+	assert.Error(t, err, "should fail to create an endpoint when rewrite refers to a missing capture group")
+	assert.Nil(t, endpoint)
+}
+
 type stubSharder struct {
 }
 
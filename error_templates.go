@@ -0,0 +1,273 @@
+package weaver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Media types an error template can be rendered as, in order of preference
+const (
+	mediaTypeJSON = "application/json"
+	mediaTypeText = "text/plain"
+	mediaTypeHTML = "text/html"
+)
+
+// ErrorDefaultTemplate - The key of the template applied to error codes without a template of their own
+const ErrorDefaultTemplate = "default"
+
+// ErrorTemplate - Overrides the response of an error weaver generates itself. JSON, Text and HTML
+// are Go templates of the body rendered with ErrorData, the one served is picked by the Accept header
+// of the request. Without a JSON template weaver's own error body is kept. Header values are
+// templates too.
+type ErrorTemplate struct {
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	JSON    string            `json:"json,omitempty"`
+	Text    string            `json:"text,omitempty"`
+	HTML    string            `json:"html,omitempty"`
+
+	parsed *parsedErrorTemplate
+}
+
+// parsedErrorTemplate - The templates of an ErrorTemplate, parsed once by Validate
+type parsedErrorTemplate struct {
+	headers map[string]*template.Template
+	json    *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+// ErrorTemplates - Error templates by weaver error code (e.g. weaver:route:not_found), see ErrorDefaultTemplate
+type ErrorTemplates map[string]*ErrorTemplate
+
+// ErrorData - The values error templates are rendered with
+type ErrorData struct {
+	ACLID     string
+	Code      string
+	Message   string
+	Status    int
+	RequestID string
+}
+
+// RenderedError - An error response rendered from a template, a nil Body keeps weaver's own body
+type RenderedError struct {
+	Status      int
+	Header      http.Header
+	ContentType string
+	Body        []byte
+}
+
+var templateFuncs = map[string]interface{}{
+	"json": func(value interface{}) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// ParseErrorTemplates - Parses and validates a JSON object of error templates, an empty value has none
+func ParseErrorTemplates(value string) (ErrorTemplates, error) {
+	templates := ErrorTemplates{}
+	if value == "" {
+		return templates, nil
+	}
+
+	if err := json.Unmarshal([]byte(value), &templates); err != nil {
+		return nil, fmt.Errorf("error templates are not a valid JSON object: %s", err)
+	}
+
+	return templates, templates.Validate()
+}
+
+// Validate - Checks every template parses, keeping the parsed templates to render them with
+func (et ErrorTemplates) Validate() error {
+	for code, tmpl := range et {
+		if tmpl == nil {
+			return fmt.Errorf("empty error template for %s", code)
+		}
+
+		if tmpl.Status != 0 && (tmpl.Status < 100 || tmpl.Status > 599) {
+			return fmt.Errorf("invalid status %d in error template for %s", tmpl.Status, code)
+		}
+
+		parsed, err := tmpl.parse()
+		if err != nil {
+			return fmt.Errorf("%s in error template for %s", err, code)
+		}
+
+		tmpl.parsed = parsed
+	}
+
+	return nil
+}
+
+func (tmpl *ErrorTemplate) parse() (*parsedErrorTemplate, error) {
+	parsed := &parsedErrorTemplate{headers: map[string]*template.Template{}}
+
+	for name, value := range tmpl.Headers {
+		header, err := parseTextTemplate(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid header %s: %s", name, err)
+		}
+
+		parsed.headers[name] = header
+	}
+
+	var err error
+	if parsed.json, err = parseTextTemplate(tmpl.JSON); err != nil {
+		return nil, fmt.Errorf("invalid json: %s", err)
+	}
+
+	if parsed.text, err = parseTextTemplate(tmpl.Text); err != nil {
+		return nil, fmt.Errorf("invalid text: %s", err)
+	}
+
+	if parsed.html, err = htmltemplate.New("html").Funcs(templateFuncs).Parse(tmpl.HTML); err != nil {
+		return nil, fmt.Errorf("invalid html: %s", err)
+	}
+
+	return parsed, nil
+}
+
+// Lookup - The template for the error code, falling back to the default template
+func (et ErrorTemplates) Lookup(code string) *ErrorTemplate {
+	if tmpl, found := et[code]; found {
+		return tmpl
+	}
+
+	return et[ErrorDefaultTemplate]
+}
+
+// Render - Renders the status, headers and the body variant accepted by the request
+func (tmpl *ErrorTemplate) Render(r *http.Request, data ErrorData) (*RenderedError, error) {
+	parsed := tmpl.parsed
+	if parsed == nil {
+		// a template that was never validated is parsed for this error only
+		var err error
+		if parsed, err = tmpl.parse(); err != nil {
+			return nil, err
+		}
+	}
+
+	rendered := &RenderedError{Status: data.Status, Header: http.Header{}, ContentType: mediaTypeJSON}
+	if tmpl.Status != 0 {
+		rendered.Status = tmpl.Status
+		data.Status = tmpl.Status
+	}
+
+	for name, value := range parsed.headers {
+		header, err := executeTemplate(value, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render header %s: %s", name, err)
+		}
+
+		rendered.Header.Set(name, string(header))
+	}
+
+	offers := []string{mediaTypeJSON}
+	if tmpl.Text != "" {
+		offers = append(offers, mediaTypeText)
+	}
+
+	if tmpl.HTML != "" {
+		offers = append(offers, mediaTypeHTML)
+	}
+
+	var err error
+	switch rendered.ContentType = negotiate(r.Header.Get("Accept"), offers); rendered.ContentType {
+	case mediaTypeHTML:
+		rendered.Body, err = executeTemplate(parsed.html, data)
+	case mediaTypeText:
+		rendered.Body, err = executeTemplate(parsed.text, data)
+	default:
+		if tmpl.JSON != "" {
+			rendered.Body, err = executeTemplate(parsed.json, data)
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s body: %s", rendered.ContentType, err)
+	}
+
+	if rendered.ContentType != mediaTypeJSON {
+		rendered.ContentType += "; charset=utf-8"
+	}
+
+	return rendered, nil
+}
+
+func parseTextTemplate(value string) (*template.Template, error) {
+	return template.New("text").Funcs(templateFuncs).Option("missingkey=error").Parse(value)
+}
+
+// executeTemplate renders a parsed text or HTML template
+func executeTemplate(tmpl interface {
+	Execute(io.Writer, interface{}) error
+}, data ErrorData) ([]byte, error) {
+	var out bytes.Buffer
+	err := tmpl.Execute(&out, data)
+	return out.Bytes(), err
+}
+
+// negotiate picks the offered media type the Accept header prefers, favouring earlier offers on a
+// tie and the first offer when nothing offered is acceptable
+func negotiate(accept string, offers []string) string {
+	if accept == "" {
+		return offers[0]
+	}
+
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// acceptQuality is the q value of the most specific media range in accept matching the media type
+func acceptQuality(accept, mediaType string) float64 {
+	quality, specificity := 0.0, -1
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+
+		var rangeSpecificity int
+		switch {
+		case rangeType == mediaType:
+			rangeSpecificity = 2
+		case rangeType == "*/*":
+			rangeSpecificity = 0
+		case strings.HasSuffix(rangeType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(rangeType, "*")):
+			rangeSpecificity = 1
+		default:
+			continue
+		}
+
+		if rangeSpecificity < specificity {
+			continue
+		}
+
+		q := 1.0
+		if value, found := params["q"]; found {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		quality, specificity = q, rangeSpecificity
+	}
+
+	return quality
+}
@@ -0,0 +1,114 @@
+package weaver
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorTemplates(t *testing.T) {
+	templates, err := ParseErrorTemplates(`{"default": {"status": 502, "json": "{\"error\": {{json .Code}}}"}}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, 502, templates[ErrorDefaultTemplate].Status)
+
+	templates, err = ParseErrorTemplates("")
+	require.NoError(t, err)
+	assert.Empty(t, templates)
+}
+
+func TestParseErrorTemplatesFailsOnInvalidTemplates(t *testing.T) {
+	_, err := ParseErrorTemplates(`{"default": {"json": "{{.Code"}}`)
+	assert.Error(t, err)
+
+	_, err = ParseErrorTemplates(`{"default": {"status": 1000}}`)
+	assert.Error(t, err)
+
+	_, err = ParseErrorTemplates(`{"default": {"headers": {"X-Error": "{{end}}"}}}`)
+	assert.Error(t, err)
+
+	_, err = ParseErrorTemplates(`[]`)
+	assert.Error(t, err)
+}
+
+func TestValidateKeepsTheParsedTemplates(t *testing.T) {
+	templates, err := ParseErrorTemplates(`{"default": {"headers": {"X-Error": "{{.Code}}"}, "text": "{{.Message}}"}}`)
+	require.NoError(t, err)
+
+	parsed := templates[ErrorDefaultTemplate].parsed
+	require.NotNil(t, parsed)
+	assert.NotNil(t, parsed.headers["X-Error"])
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/plain")
+
+	rendered, err := templates[ErrorDefaultTemplate].Render(r, ErrorData{Code: "weaver:route:not_found", Message: "gone"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "weaver:route:not_found", rendered.Header.Get("X-Error"))
+	assert.Equal(t, "gone", string(rendered.Body))
+	assert.True(t, parsed == templates[ErrorDefaultTemplate].parsed, "should not have parsed the templates again")
+}
+
+func TestErrorTemplatesLookupFallsBackToDefault(t *testing.T) {
+	notFound := &ErrorTemplate{Status: 410}
+	fallback := &ErrorTemplate{Status: 500}
+
+	templates := ErrorTemplates{"weaver:route:not_found": notFound, ErrorDefaultTemplate: fallback}
+
+	assert.Equal(t, notFound, templates.Lookup("weaver:route:not_found"))
+	assert.Equal(t, fallback, templates.Lookup("weaver:upstream:timeout"))
+	assert.Nil(t, ErrorTemplates(nil).Lookup("weaver:upstream:timeout"))
+}
+
+func TestErrorTemplateRendersVariantAcceptedByRequest(t *testing.T) {
+	tmpl := &ErrorTemplate{
+		Status:  503,
+		Headers: map[string]string{"X-Error-Code": "{{.Code}}"},
+		JSON:    `{"error": {"code": {{json .Code}}, "request_id": {{json .RequestID}}, "status": {{.Status}}}}`,
+		Text:    "{{.Code}} for {{.ACLID}}",
+		HTML:    "<p>{{.Message}}</p>",
+	}
+
+	data := ErrorData{ACLID: "svc-01", Code: "weaver:service:unavailable", Message: "<down>", Status: 500, RequestID: "req-1"}
+
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "application/json", `{"error": {"code": "weaver:service:unavailable", "request_id": "req-1", "status": 503}}`},
+		{"application/json", "application/json", `{"error": {"code": "weaver:service:unavailable", "request_id": "req-1", "status": 503}}`},
+		{"text/plain", "text/plain; charset=utf-8", "weaver:service:unavailable for svc-01"},
+		{"text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", "<p>&lt;down&gt;</p>"},
+		{"text/*;q=0.5, application/json;q=0.1", "text/plain; charset=utf-8", "weaver:service:unavailable for svc-01"},
+		{"image/png", "application/json", `{"error": {"code": "weaver:service:unavailable", "request_id": "req-1", "status": 503}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept", tt.accept)
+
+			rendered, err := tmpl.Render(r, data)
+			require.NoError(t, err)
+
+			assert.Equal(t, 503, rendered.Status)
+			assert.Equal(t, "weaver:service:unavailable", rendered.Header.Get("X-Error-Code"))
+			assert.Equal(t, tt.contentType, rendered.ContentType)
+			assert.Equal(t, tt.body, string(rendered.Body))
+		})
+	}
+}
+
+func TestErrorTemplateWithoutJSONKeepsTheBody(t *testing.T) {
+	tmpl := &ErrorTemplate{Status: 502}
+
+	rendered, err := tmpl.Render(httptest.NewRequest("GET", "/", nil), ErrorData{Status: 503})
+	require.NoError(t, err)
+
+	assert.Equal(t, 502, rendered.Status)
+	assert.Nil(t, rendered.Body)
+}
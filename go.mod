@@ -1,19 +1,34 @@
 module github.com/gojektech/weaver
 
+go 1.24
+
+require (
+	github.com/coreos/etcd v3.3.0+incompatible
+	github.com/getsentry/raven-go v0.0.0-20161115135411-3f7439d3e74d
+	github.com/gojekfarm/hashring v0.0.0-20180330151038-7bba2fd52501
+	github.com/golang/geo v0.0.0-20170430223333-5747e9816367
+	github.com/newrelic/go-agent v1.11.0
+	github.com/pkg/errors v0.8.0
+	github.com/savaki/jq v0.0.0-20161209013833-0e6baecebbf8
+	github.com/sirupsen/logrus v1.0.3
+	github.com/spf13/viper v1.0.0
+	github.com/stretchr/testify v1.2.2
+	github.com/vulcand/route v0.0.0-20160805191529-61904570391b
+	gopkg.in/alexcesaro/statsd.v2 v2.0.0
+	gopkg.in/urfave/cli.v1 v1.20.0
+)
+
 require (
 	github.com/certifi/gocertifi v0.0.0-20170123212243-03be5e6bb987 // indirect
 	github.com/coreos/bbolt v1.3.2 // indirect
-	github.com/coreos/etcd v3.3.0+incompatible
 	github.com/coreos/go-semver v0.2.0 // indirect
 	github.com/coreos/go-systemd v0.0.0-20181031085051-9002847aa142 // indirect
 	github.com/coreos/pkg v0.0.0-20180928190104-399ea9e2e55f // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
-	github.com/getsentry/raven-go v0.0.0-20161115135411-3f7439d3e74d
+	github.com/fsnotify/fsnotify v1.4.7 // indirect
 	github.com/ghodss/yaml v1.0.0 // indirect
 	github.com/gogo/protobuf v1.2.0 // indirect
-	github.com/gojekfarm/hashring v0.0.0-20180330151038-7bba2fd52501
-	github.com/golang/geo v0.0.0-20170430223333-5747e9816367
 	github.com/golang/groupcache v0.0.0-20190129154638-5b532d6fd5ef // indirect
 	github.com/google/btree v0.0.0-20180813153112-4030bb1f1f0c // indirect
 	github.com/gorilla/websocket v1.4.0 // indirect
@@ -26,41 +41,36 @@ require (
 	github.com/magiconair/properties v0.0.0-20170113111004-b3b15ef068fd // indirect
 	github.com/mailru/easyjson v0.0.0-20180823135443-60711f1a8329 // indirect
 	github.com/mitchellh/mapstructure v0.0.0-20170125051937-db1efb556f84 // indirect
-	github.com/newrelic/go-agent v1.11.0
 	github.com/onsi/ginkgo v1.7.0 // indirect
 	github.com/onsi/gomega v1.4.3 // indirect
 	github.com/pelletier/go-buffruneio v0.2.0 // indirect
 	github.com/pelletier/go-toml v0.0.0-20170227222904-361678322880 // indirect
 	github.com/philhofer/fwd v1.0.0 // indirect
-	github.com/pkg/errors v0.8.0
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/pquerna/ffjson v0.0.0-20181028064349-e517b90714f7 // indirect
 	github.com/prometheus/client_golang v0.9.2 // indirect
 	github.com/prometheus/client_model v0.0.0-20190129233127-fd36f4220a90 // indirect
-	github.com/savaki/jq v0.0.0-20161209013833-0e6baecebbf8
-	github.com/sirupsen/logrus v1.0.3
 	github.com/soheilhy/cmux v0.1.4 // indirect
 	github.com/spaolacci/murmur3 v0.0.0-20170819071325-9f5d223c6079 // indirect
 	github.com/spf13/afero v0.0.0-20170217164146-9be650865eab // indirect
 	github.com/spf13/cast v0.0.0-20170221152302-f820543c3592 // indirect
 	github.com/spf13/jwalterweatherman v0.0.0-20170109133355-fa7ca7e836cf // indirect
 	github.com/spf13/pflag v1.0.0 // indirect
-	github.com/spf13/viper v1.0.0
 	github.com/stretchr/objx v0.1.1 // indirect
-	github.com/stretchr/testify v1.2.2
 	github.com/tinylib/msgp v1.1.0 // indirect
 	github.com/tmc/grpc-websocket-proxy v0.0.0-20190109142713-0ad062ec5ee5 // indirect
 	github.com/ugorji/go v0.0.0-20171019201919-bdcc60b419d1 // indirect
 	github.com/vulcand/predicate v1.0.0 // indirect
-	github.com/vulcand/route v0.0.0-20160805191529-61904570391b
 	github.com/xiang90/probing v0.0.0-20190116061207-43a291ad63a2 // indirect
 	go.etcd.io/bbolt v1.3.2 // indirect
 	golang.org/x/crypto v0.0.0-20190131182504-b8fe1690c613 // indirect
+	golang.org/x/net v0.0.0-20181201002055-351d144fa1fc // indirect
+	golang.org/x/sys v0.0.0-20180909124046-d0be0721c37e // indirect
+	golang.org/x/text v0.3.0 // indirect
 	golang.org/x/time v0.0.0-20181108054448-85acf8d2951c // indirect
 	google.golang.org/grpc v1.18.0 // indirect
 	gopkg.in/airbrake/gobrake.v2 v2.0.9 // indirect
-	gopkg.in/alexcesaro/statsd.v2 v2.0.0
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/gemnasium/logrus-airbrake-hook.v2 v2.1.2 // indirect
-	gopkg.in/urfave/cli.v1 v1.20.0
+	gopkg.in/yaml.v2 v2.2.1 // indirect
 )
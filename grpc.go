@@ -0,0 +1,54 @@
+package weaver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gRPC status codes weaver answers with, as defined in google.golang.org/grpc/codes
+const (
+	GRPCStatusCanceled          = 1
+	GRPCStatusDeadlineExceeded  = 4
+	GRPCStatusResourceExhausted = 8
+	GRPCStatusUnimplemented     = 12
+	GRPCStatusInternal          = 13
+	GRPCStatusUnavailable       = 14
+)
+
+// IsGRPCRequest - Tells whether the request is a gRPC call, gRPC-Web is proxied as plain HTTP
+func IsGRPCRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/grpc") {
+		return false
+	}
+
+	rest := contentType[len("application/grpc"):]
+	return rest == "" || rest[0] == '+' || rest[0] == ';'
+}
+
+// WriteGRPCError - Answers a gRPC call with a trailers-only response carrying the status code
+func WriteGRPCError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", encodeGRPCMessage(message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// encodeGRPCMessage percent-encodes the message as required by the gRPC over HTTP/2 spec
+func encodeGRPCMessage(message string) string {
+	var encoded strings.Builder
+
+	for idx := 0; idx < len(message); idx++ {
+		c := message[idx]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&encoded, "%%%02X", c)
+			continue
+		}
+
+		encoded.WriteByte(c)
+	}
+
+	return encoded.String()
+}
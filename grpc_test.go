@@ -0,0 +1,37 @@
+package weaver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsGRPCRequest(t *testing.T) {
+	for contentType, expected := range map[string]bool{
+		"application/grpc":                true,
+		"application/grpc+proto":          true,
+		"application/grpc; charset=utf-8": true,
+		"application/grpc-web":            false,
+		"application/grpc-web-text+proto": false,
+		"application/json":                false,
+		"":                                false,
+	} {
+		r := httptest.NewRequest("POST", "/gojek.drivers.v1.Drivers/Locate", nil)
+		r.Header.Set("Content-Type", contentType)
+
+		assert.Equal(t, expected, IsGRPCRequest(r), contentType)
+	}
+}
+
+func TestWriteGRPCError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteGRPCError(w, GRPCStatusUnavailable, "no backend for 100% of drivers")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/grpc", w.Header().Get("Content-Type"))
+	assert.Equal(t, "14", w.Header().Get("Grpc-Status"))
+	assert.Equal(t, "no backend for 100%25 of drivers", w.Header().Get("Grpc-Message"))
+	assert.Empty(t, w.Body.String())
+}
@@ -0,0 +1,88 @@
+package weaver
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var reHeaderVar = regexp.MustCompile(`\{\{[^}]*\}\}`)
+
+var headerVars = map[string]bool{
+	"{{acl_id}}":       true,
+	"{{backend_name}}": true,
+	"{{shard_key}}":    true,
+}
+
+// HeaderConfig - Defines header operations on the upstream request and the downstream response
+type HeaderConfig struct {
+	Request  *HeaderOps `json:"request,omitempty"`
+	Response *HeaderOps `json:"response,omitempty"`
+}
+
+// HeaderOps - Headers to remove, then set, then add. Values may use {{acl_id}}, {{backend_name}}
+// and {{shard_key}}.
+type HeaderOps struct {
+	Remove []string          `json:"remove,omitempty"`
+	Set    map[string]string `json:"set,omitempty"`
+	Add    map[string]string `json:"add,omitempty"`
+}
+
+func (hc *HeaderConfig) Validate() error {
+	if hc == nil {
+		return nil
+	}
+
+	if err := hc.Request.validate(); err != nil {
+		return fmt.Errorf("invalid request headers: %s", err)
+	}
+
+	if err := hc.Response.validate(); err != nil {
+		return fmt.Errorf("invalid response headers: %s", err)
+	}
+
+	return nil
+}
+
+func (ops *HeaderOps) validate() error {
+	if ops == nil {
+		return nil
+	}
+
+	for _, values := range []map[string]string{ops.Set, ops.Add} {
+		for name, value := range values {
+			for _, variable := range reHeaderVar.FindAllString(value, -1) {
+				if !headerVars[variable] {
+					return fmt.Errorf("unknown variable %s in value of %s", variable, name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ops *HeaderOps) apply(header http.Header, route *RequestRoute) {
+	if ops == nil {
+		return
+	}
+
+	for _, name := range ops.Remove {
+		header.Del(name)
+	}
+
+	vars := strings.NewReplacer(
+		"{{acl_id}}", route.ACL.ID,
+		"{{backend_name}}", route.Backend.Name,
+		"{{shard_key}}", route.ShardKey,
+	)
+
+	for name, value := range ops.Set {
+		header.Set(name, vars.Replace(value))
+	}
+
+	for name, value := range ops.Add {
+		header.Add(name, vars.Replace(value))
+	}
+}
@@ -0,0 +1,66 @@
+package weaver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderConfigValidate(t *testing.T) {
+	headerConfig := &HeaderConfig{}
+	err := json.Unmarshal([]byte(`{
+		"request": {
+			"set": { "X-Weaver-Shard": "{{backend_name}}", "X-Shard-Key": "{{shard_key}}" },
+			"add": { "X-Weaver-ACL": "acl-{{acl_id}}" }
+		},
+		"response": { "remove": ["X-Internal"] }
+	}`), headerConfig)
+	require.NoError(t, err, "should not have failed to unmarshal header config")
+
+	assert.NoError(t, headerConfig.Validate())
+}
+
+func TestHeaderConfigValidateFailsOnUnknownVariable(t *testing.T) {
+	headerConfig := &HeaderConfig{
+		Response: &HeaderOps{
+			Set: map[string]string{"X-Weaver-Shard": "{{backend}}"},
+		},
+	}
+
+	assert.Error(t, headerConfig.Validate())
+}
+
+func TestNilHeaderConfigIsValid(t *testing.T) {
+	var headerConfig *HeaderConfig
+
+	assert.NoError(t, headerConfig.Validate())
+}
+
+func TestHeaderOpsApply(t *testing.T) {
+	route := &RequestRoute{
+		ACL:      &ACL{ID: "svc-01"},
+		Backend:  &Backend{Name: "shard-01"},
+		ShardKey: "123",
+	}
+
+	headerOps := &HeaderOps{
+		Remove: []string{"X-Internal", "X-Weaver-Shard"},
+		Set:    map[string]string{"X-Weaver-Shard": "{{backend_name}}", "X-Shard-Key": "{{acl_id}}:{{shard_key}}"},
+		Add:    map[string]string{"X-Forwarded-By": "weaver"},
+	}
+
+	header := http.Header{}
+	header.Set("X-Internal", "secret")
+	header.Set("X-Weaver-Shard", "spoofed")
+	header.Set("X-Forwarded-By", "edge")
+
+	headerOps.apply(header, route)
+
+	assert.Equal(t, "", header.Get("X-Internal"))
+	assert.Equal(t, "shard-01", header.Get("X-Weaver-Shard"))
+	assert.Equal(t, "svc-01:123", header.Get("X-Shard-Key"))
+	assert.Equal(t, []string{"edge", "weaver"}, header["X-Forwarded-By"])
+}
@@ -0,0 +1,282 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/instrumentation"
+	"github.com/gojektech/weaver/pkg/redact"
+	"github.com/gojektech/weaver/pkg/util"
+	"github.com/pkg/errors"
+)
+
+// Entry - One proxied request as recorded in the access log
+type Entry struct {
+	Time           time.Time
+	RequestID      string
+	Request        *http.Request
+	Status         int
+	UpstreamStatus int
+	BytesIn        int64
+	BytesOut       int64
+	Latency        time.Duration
+	APIName        string
+	Backend        string
+	DownstreamHost string
+	ShardKey       string
+
+	// Redaction - The policy the request is logged with, the default policy when nil
+	Redaction *redact.Policy
+
+	// Sampling - The ACL's sampling, overriding the logger's
+	Sampling *SamplingOverride
+}
+
+// Field names of json access logs
+const (
+	FieldTime           = "time"
+	FieldRequestID      = "request_id"
+	FieldRemoteAddr     = "remote_addr"
+	FieldMethod         = "method"
+	FieldURI            = "uri"
+	FieldProtocol       = "protocol"
+	FieldHost           = "host"
+	FieldStatus         = "status"
+	FieldUpstreamStatus = "upstream_status"
+	FieldBytesIn        = "bytes_in"
+	FieldBytesOut       = "bytes_out"
+	FieldLatency        = "latency_ms"
+	FieldAPIName        = "api_name"
+	FieldBackend        = "backend"
+	FieldDownstreamHost = "downstream_host"
+	FieldShardKey       = "shard_key"
+	FieldUserAgent      = "user_agent"
+	FieldReferer        = "referer"
+	FieldRequestHeaders = "request_headers"
+)
+
+var fieldValues = map[string]func(e *Entry) interface{}{
+	FieldTime:           func(e *Entry) interface{} { return e.Time.Format(time.RFC3339Nano) },
+	FieldRequestID:      func(e *Entry) interface{} { return e.RequestID },
+	FieldRemoteAddr:     func(e *Entry) interface{} { return e.Request.RemoteAddr },
+	FieldMethod:         func(e *Entry) interface{} { return e.Request.Method },
+	FieldURI:            func(e *Entry) interface{} { return e.redaction().URL(requestURI(e.Request)) },
+	FieldProtocol:       func(e *Entry) interface{} { return e.Request.Proto },
+	FieldHost:           func(e *Entry) interface{} { return e.Request.Host },
+	FieldStatus:         func(e *Entry) interface{} { return e.Status },
+	FieldUpstreamStatus: func(e *Entry) interface{} { return e.UpstreamStatus },
+	FieldBytesIn:        func(e *Entry) interface{} { return e.BytesIn },
+	FieldBytesOut:       func(e *Entry) interface{} { return e.BytesOut },
+	FieldLatency:        func(e *Entry) interface{} { return float64(e.Latency) / float64(time.Millisecond) },
+	FieldAPIName:        func(e *Entry) interface{} { return e.APIName },
+	FieldBackend:        func(e *Entry) interface{} { return e.Backend },
+	FieldDownstreamHost: func(e *Entry) interface{} { return e.DownstreamHost },
+	FieldShardKey:       func(e *Entry) interface{} { return e.ShardKey },
+	FieldUserAgent:      func(e *Entry) interface{} { return e.Request.UserAgent() },
+	FieldReferer:        func(e *Entry) interface{} { return e.redaction().URL(e.Request.Referer()) },
+	FieldRequestHeaders: func(e *Entry) interface{} { return requestHeaders(e) },
+}
+
+// DefaultFields - The fields of json access logs unless ACCESS_LOG_FIELDS is set
+var DefaultFields = []string{
+	FieldTime, FieldRequestID, FieldRemoteAddr, FieldMethod, FieldURI, FieldProtocol, FieldHost, FieldStatus,
+	FieldUpstreamStatus, FieldBytesIn, FieldBytesOut, FieldLatency, FieldAPIName, FieldBackend, FieldDownstreamHost,
+	FieldShardKey, FieldUserAgent, FieldReferer,
+}
+
+type formatter func(e *Entry) []byte
+
+// Logger - Writes access log entries in one format to one output
+type Logger struct {
+	mu       sync.Mutex
+	format   formatter
+	out      io.Writer
+	sampling Sampling
+	sampler  sampler
+}
+
+var accessLogger *Logger
+
+// Setup - Creates the access logger from the ACCESS_LOG_* configuration
+func Setup() error {
+	cfg := config.AccessLog()
+	if !cfg.Enabled() {
+		accessLogger = nil
+		return nil
+	}
+
+	out, err := newOutput(cfg)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s access log output", cfg.Output())
+	}
+
+	logger, err := New(cfg.Format(), cfg.Fields(), out)
+	if err != nil {
+		return err
+	}
+
+	logger.SetSampling(Sampling{Rate: cfg.SampleRate(), SlowThresholdInMS: int64(cfg.SlowThresholdInMS())})
+	accessLogger = logger
+
+	return nil
+}
+
+// New - Creates an access logger writing entries in the format (json, common or combined) to out
+func New(format string, fields []string, out io.Writer) (*Logger, error) {
+	switch format {
+	case "common":
+		return &Logger{format: commonLogFormat, out: out}, nil
+	case "combined":
+		return &Logger{format: combinedLogFormat, out: out}, nil
+	case "json":
+		if len(fields) == 0 {
+			fields = DefaultFields
+		}
+
+		for _, field := range fields {
+			if _, found := fieldValues[field]; !found {
+				return nil, fmt.Errorf("unknown access log field: %s", field)
+			}
+		}
+
+		return &Logger{format: jsonFormat(fields), out: out}, nil
+	}
+
+	return nil, fmt.Errorf("unknown access log format: %s", format)
+}
+
+// SetLogger - Replaces the access logger, nil turns access logging off
+func SetLogger(l *Logger) {
+	accessLogger = l
+}
+
+// Log - Writes the entry to the access log, when access logging is enabled
+func Log(e *Entry) {
+	if accessLogger != nil {
+		accessLogger.Log(e)
+	}
+}
+
+// SetSampling - Sets the sampling of entries without their own
+func (l *Logger) SetSampling(sampling Sampling) {
+	l.sampling = sampling
+}
+
+// Log - Writes the entry unless it is sampled out, which is counted instead
+func (l *Logger) Log(e *Entry) {
+	if !l.sampler.sampled(e, l.sampling.Override(e.Sampling)) {
+		instrumentation.MetricsFromContext(e.Request.Context()).IncrementAccessLogSampledOut(e.APIName)
+		return
+	}
+
+	line := l.format(e)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.out.Write(line); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write access log: %s\n", err)
+	}
+}
+
+func jsonFormat(fields []string) formatter {
+	return func(e *Entry) []byte {
+		values := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			values[field] = fieldValues[field](e)
+		}
+
+		line, _ := json.Marshal(values)
+		return append(line, '\n')
+	}
+}
+
+// commonLogFormat writes host ident authuser [date] "request line" status bytes
+func commonLogFormat(e *Entry) []byte {
+	var line bytes.Buffer
+	writeCommonLogFormat(&line, e)
+	line.WriteByte('\n')
+
+	return line.Bytes()
+}
+
+// combinedLogFormat is the common log format followed by "referer" "user agent"
+func combinedLogFormat(e *Entry) []byte {
+	var line bytes.Buffer
+	writeCommonLogFormat(&line, e)
+	fmt.Fprintf(&line, " %s %s\n", quote(e.redaction().URL(e.Request.Referer())), quote(e.Request.UserAgent()))
+
+	return line.Bytes()
+}
+
+func writeCommonLogFormat(line *bytes.Buffer, e *Entry) {
+	host, _, err := net.SplitHostPort(e.Request.RemoteAddr)
+	if err != nil {
+		host = e.Request.RemoteAddr
+	}
+
+	user := "-"
+	if username, _, ok := e.Request.BasicAuth(); ok && username != "" {
+		user = username
+	}
+
+	bytesOut := "-"
+	if e.BytesOut > 0 {
+		bytesOut = strconv.FormatInt(e.BytesOut, 10)
+	}
+
+	fmt.Fprintf(line, "%s - %s [%s] %s %d %s", orDash(host), user, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		quote(fmt.Sprintf("%s %s %s", e.Request.Method, e.redaction().URL(requestURI(e.Request)), e.Request.Proto)), e.Status, bytesOut)
+}
+
+func requestURI(r *http.Request) string {
+	if r.RequestURI != "" {
+		return r.RequestURI
+	}
+
+	return r.URL.RequestURI()
+}
+
+func quote(value string) string {
+	if value == "" {
+		return `"-"`
+	}
+
+	return strconv.Quote(value)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func (e *Entry) redaction() *redact.Policy {
+	if e.Redaction != nil {
+		return e.Redaction
+	}
+
+	return redact.Default()
+}
+
+func requestHeaders(e *Entry) map[string]string {
+	policy := e.redaction()
+
+	headers := map[string]string{}
+	for k := range e.Request.Header {
+		headers[util.ToSnake(k)] = policy.Header(k, e.Request.Header.Get(k))
+	}
+
+	return headers
+}
@@ -0,0 +1,122 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gojektech/weaver/pkg/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntry() *Entry {
+	r := httptest.NewRequest("POST", "/drivers/1?city=jkt", nil)
+	r.RemoteAddr = "10.0.0.1:54321"
+	r.Header.Set("User-Agent", "driver-app/1.0")
+	r.Header.Set("Referer", "https://gojek.com/")
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("X-City", "jkt")
+
+	return &Entry{
+		Time:           time.Date(2018, time.October, 10, 13, 55, 36, 0, time.FixedZone("", 7*60*60)),
+		RequestID:      "req-1",
+		Request:        r,
+		Status:         201,
+		UpstreamStatus: 201,
+		BytesIn:        12,
+		BytesOut:       2326,
+		Latency:        1500 * time.Microsecond,
+		APIName:        "drivers",
+		Backend:        "foo",
+		DownstreamHost: "http://foo.golabs.io",
+		ShardKey:       "1",
+	}
+}
+
+func TestCommonLogFormat(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New("common", nil, &out)
+	require.NoError(t, err)
+
+	l.Log(testEntry())
+
+	assert.Equal(t, "10.0.0.1 - - [10/Oct/2018:13:55:36 +0700] \"POST /drivers/1?city=jkt HTTP/1.1\" 201 2326\n", out.String())
+}
+
+func TestCombinedLogFormat(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New("combined", nil, &out)
+	require.NoError(t, err)
+
+	entry := testEntry()
+	entry.BytesOut = 0
+	l.Log(entry)
+
+	assert.Equal(t, "10.0.0.1 - - [10/Oct/2018:13:55:36 +0700] \"POST /drivers/1?city=jkt HTTP/1.1\" 201 - \"https://gojek.com/\" \"driver-app/1.0\"\n", out.String())
+}
+
+func TestJSONFormatWithDefaultFields(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New("json", nil, &out)
+	require.NoError(t, err)
+
+	l.Log(testEntry())
+
+	var logged map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &logged))
+
+	assert.Len(t, logged, len(DefaultFields))
+	assert.Equal(t, "req-1", logged["request_id"])
+	assert.Equal(t, 1.5, logged["latency_ms"])
+	assert.Equal(t, float64(12), logged["bytes_in"])
+	assert.Equal(t, float64(2326), logged["bytes_out"])
+	assert.Equal(t, float64(201), logged["upstream_status"])
+	assert.Equal(t, "drivers", logged["api_name"])
+	assert.Equal(t, "1", logged["shard_key"])
+	assert.NotContains(t, logged, "request_headers")
+}
+
+func TestJSONFormatWithSelectedFields(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New("json", []string{"status", "backend", "request_headers"}, &out)
+	require.NoError(t, err)
+
+	l.Log(testEntry())
+
+	assert.JSONEq(t, `{"status": 201, "backend": "foo", "request_headers": {"authorization": "[REDACTED]", "user_agent": "driver-app/1.0", "referer": "https://gojek.com/", "x_city": "jkt"}}`, out.String())
+}
+
+func TestEntriesAreLoggedWithTheirRedactionPolicy(t *testing.T) {
+	policy := &redact.Policy{DenyHeaders: []string{"X-City"}, QueryParams: []string{"^city$"}}
+	require.NoError(t, policy.Compile())
+
+	var out bytes.Buffer
+	l, err := New("json", []string{"uri", "request_headers"}, &out)
+	require.NoError(t, err)
+
+	entry := testEntry()
+	entry.Redaction = policy
+	l.Log(entry)
+
+	assert.JSONEq(t, `{"uri": "/drivers/1?city=[REDACTED]", "request_headers": {"user_agent": "driver-app/1.0",
+		"referer": "https://gojek.com/", "x_city": "[REDACTED]", "authorization": "Bearer secret"}}`, out.String())
+
+	out.Reset()
+	l, err = New("common", nil, &out)
+	require.NoError(t, err)
+
+	l.Log(entry)
+
+	assert.Contains(t, out.String(), `"POST /drivers/1?city=[REDACTED] HTTP/1.1"`)
+}
+
+func TestNewFailsOnUnknownFieldOrFormat(t *testing.T) {
+	_, err := New("json", []string{"status", "colour"}, &bytes.Buffer{})
+	assert.EqualError(t, err, "unknown access log field: colour")
+
+	_, err = New("apache", nil, &bytes.Buffer{})
+	assert.EqualError(t, err, "unknown access log format: apache")
+}
@@ -0,0 +1,58 @@
+package accesslog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFileRotatesPastMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "access.log")
+	rf, err := newRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	defer rf.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := rf.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	assertFile(t, path, "fourth\n")
+	assertFile(t, path+".1", "third\n")
+	assertFile(t, path+".2", "second\n")
+
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err), "should have kept only two backups")
+}
+
+func TestRotatingFileAppendsToExistingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "accesslog")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "access.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("old\n"), 0644))
+
+	rf, err := newRotatingFile(path, 1024, 1)
+	require.NoError(t, err)
+	defer rf.Close()
+
+	_, err = rf.Write([]byte("new\n"))
+	require.NoError(t, err)
+
+	assertFile(t, path, "old\nnew\n")
+}
+
+func assertFile(t *testing.T, path, expected string) {
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
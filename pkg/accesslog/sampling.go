@@ -0,0 +1,61 @@
+package accesslog
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sampling - Logs 1 in Rate successful requests, errors and requests slower than the threshold are
+// always logged. A Rate of 0 or 1 logs every request and a threshold of 0 leaves it off.
+type Sampling struct {
+	Rate              int   `json:"sample_rate,omitempty"`
+	SlowThresholdInMS int64 `json:"slow_threshold_in_ms,omitempty"`
+}
+
+// SamplingOverride - Sampling settings of an ACL, the settings left unset are the logger's. An
+// explicit 0 is kept, so an ACL can log every request or turn the slow threshold off.
+type SamplingOverride struct {
+	Rate              *int   `json:"sample_rate,omitempty"`
+	SlowThresholdInMS *int64 `json:"slow_threshold_in_ms,omitempty"`
+}
+
+// Override - The sampling with the settings made in override taking precedence, override may be nil
+func (s Sampling) Override(override *SamplingOverride) Sampling {
+	if override == nil {
+		return s
+	}
+
+	if override.Rate != nil {
+		s.Rate = *override.Rate
+	}
+
+	if override.SlowThresholdInMS != nil {
+		s.SlowThresholdInMS = *override.SlowThresholdInMS
+	}
+
+	return s
+}
+
+// sampler counts successful requests per API to log every Rate-th one
+type sampler struct {
+	counters sync.Map
+}
+
+func (sp *sampler) sampled(e *Entry, sampling Sampling) bool {
+	if e.Status < http.StatusContinue || e.Status >= http.StatusBadRequest {
+		return true
+	}
+
+	if sampling.SlowThresholdInMS > 0 && e.Latency >= time.Duration(sampling.SlowThresholdInMS)*time.Millisecond {
+		return true
+	}
+
+	if sampling.Rate <= 1 {
+		return true
+	}
+
+	counter, _ := sp.counters.LoadOrStore(e.APIName, new(uint64))
+	return (atomic.AddUint64(counter.(*uint64), 1)-1)%uint64(sampling.Rate) == 0
+}
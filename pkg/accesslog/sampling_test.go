@@ -0,0 +1,92 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerSamplesSuccessfulRequests(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New("json", []string{"status"}, &out)
+	require.NoError(t, err)
+
+	l.SetSampling(Sampling{Rate: 3})
+
+	for i := 0; i < 7; i++ {
+		entry := testEntry()
+		entry.Status = 200
+		l.Log(entry)
+	}
+
+	assert.Equal(t, 3, strings.Count(out.String(), "\n"), "should have logged the 1st, 4th and 7th requests")
+}
+
+func TestLoggerAlwaysLogsErrorsAndSlowRequests(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New("json", []string{"status"}, &out)
+	require.NoError(t, err)
+
+	l.SetSampling(Sampling{Rate: 1000, SlowThresholdInMS: 100})
+
+	for _, status := range []int{200, 404, 503, 0} {
+		entry := testEntry()
+		entry.Status = status
+		l.Log(entry)
+	}
+
+	slow := testEntry()
+	slow.Status = 200
+	slow.Latency = 150 * time.Millisecond
+	l.Log(slow)
+
+	assert.Equal(t, "{\"status\":200}\n{\"status\":404}\n{\"status\":503}\n{\"status\":0}\n{\"status\":200}\n", out.String())
+}
+
+func TestLoggerSamplesWithTheACLOverride(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New("json", []string{"api_name"}, &out)
+	require.NoError(t, err)
+
+	l.SetSampling(Sampling{Rate: 1000})
+
+	for i := 0; i < 4; i++ {
+		entry := testEntry()
+		entry.APIName = "orders"
+		entry.Sampling = &SamplingOverride{Rate: intPtr(2)}
+		l.Log(entry)
+
+		entry = testEntry()
+		l.Log(entry)
+	}
+
+	assert.Equal(t, 2, strings.Count(out.String(), "orders"))
+	assert.Equal(t, 1, strings.Count(out.String(), "drivers"))
+}
+
+func TestSamplingOverride(t *testing.T) {
+	global := Sampling{Rate: 10, SlowThresholdInMS: 500}
+
+	assert.Equal(t, global, global.Override(nil))
+	assert.Equal(t, global, global.Override(&SamplingOverride{}))
+	assert.Equal(t, Sampling{Rate: 1, SlowThresholdInMS: 500}, global.Override(&SamplingOverride{Rate: intPtr(1)}))
+	assert.Equal(t, Sampling{Rate: 10, SlowThresholdInMS: 50}, global.Override(&SamplingOverride{SlowThresholdInMS: int64Ptr(50)}))
+	assert.Equal(t, Sampling{Rate: 0, SlowThresholdInMS: 0}, global.Override(&SamplingOverride{Rate: intPtr(0), SlowThresholdInMS: int64Ptr(0)}))
+}
+
+func TestSamplingOverrideFromJSONKeepsAnExplicitZero(t *testing.T) {
+	var override SamplingOverride
+	require.NoError(t, json.Unmarshal([]byte(`{"slow_threshold_in_ms": 0}`), &override))
+
+	assert.Nil(t, override.Rate)
+	assert.Equal(t, Sampling{Rate: 10}, Sampling{Rate: 10, SlowThresholdInMS: 500}.Override(&override))
+}
+
+func intPtr(i int) *int { return &i }
+
+func int64Ptr(i int64) *int64 { return &i }
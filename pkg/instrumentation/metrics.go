@@ -0,0 +1,153 @@
+package instrumentation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const metricsKey ctxKey = 1
+
+// Sink - Receives weaver's metrics, the default sink sends them to the statsd client
+type Sink interface {
+	Increment(bucket string)
+	Gauge(bucket string, value int)
+	Timing(bucket string, duration time.Duration)
+}
+
+// Metrics - The metrics of proxied requests, sent to a sink
+type Metrics struct {
+	sink Sink
+
+	upgradedMu sync.Mutex
+	upgraded   map[string]int
+}
+
+// Timing - Measures a latency from when it was created
+type Timing struct {
+	start time.Time
+}
+
+var defaultMetrics = NewMetrics(statsDSink{})
+
+// NewMetrics - Creates metrics sent to the sink, a nil sink drops them
+func NewMetrics(sink Sink) *Metrics {
+	if sink == nil {
+		sink = discardSink{}
+	}
+
+	return &Metrics{sink: sink, upgraded: map[string]int{}}
+}
+
+// Default - The metrics sent to the statsd client set up from STATSD_*
+func Default() *Metrics {
+	return defaultMetrics
+}
+
+// NewMetricsContext - Returns a copy of ctx carrying the metrics requests are counted in
+func NewMetricsContext(ctx context.Context, metrics *Metrics) context.Context {
+	return context.WithValue(ctx, metricsKey, metrics)
+}
+
+// MetricsFromContext - The metrics carried by ctx, the default metrics when there are none
+func MetricsFromContext(ctx context.Context) *Metrics {
+	if metrics, ok := ctx.Value(metricsKey).(*Metrics); ok && metrics != nil {
+		return metrics
+	}
+
+	return defaultMetrics
+}
+
+func NewTiming() Timing {
+	return Timing{start: time.Now()}
+}
+
+func (m *Metrics) IncrementTotalRequestCount() {
+	m.sink.Increment("request.total.count")
+}
+
+func (m *Metrics) IncrementAPIRequestCount(apiName string) {
+	m.sink.Increment(fmt.Sprintf("request.api.%s.count", apiName))
+}
+
+func (m *Metrics) IncrementAPIStatusCount(apiName string, httpStatusCode int) {
+	m.sink.Increment(fmt.Sprintf("request.api.%s.status.%d.count", apiName, httpStatusCode))
+}
+
+func (m *Metrics) IncrementAPIBackendRequestCount(apiName, backendName string) {
+	m.sink.Increment(fmt.Sprintf("request.api.%s.backend.%s.count", apiName, backendName))
+}
+
+func (m *Metrics) IncrementAPIBackendStatusCount(apiName, backendName string, httpStatusCode int) {
+	m.sink.Increment(fmt.Sprintf("request.api.%s.backend.%s.status.%d.count", apiName, backendName, httpStatusCode))
+}
+
+func (m *Metrics) IncrementCrashCount() {
+	m.sink.Increment("request.internal.crash.count")
+}
+
+func (m *Metrics) IncrementNotFound() {
+	m.sink.Increment(fmt.Sprintf("request.internal.%d.count", http.StatusNotFound))
+}
+
+func (m *Metrics) IncrementInternalAPIStatusCount(aclName string, statusCode int) {
+	m.sink.Increment(fmt.Sprintf("request.api.%s.internal.status.%d.count", aclName, statusCode))
+}
+
+func (m *Metrics) IncrementAPIBackendUpstreamErrorCount(apiName, backendName, failure string) {
+	m.sink.Increment(fmt.Sprintf("request.api.%s.backend.%s.upstream.%s.count", apiName, backendName, failure))
+}
+
+func (m *Metrics) IncrementAPIAuthFailureCount(apiName, reason string) {
+	m.sink.Increment(fmt.Sprintf("request.api.%s.auth.%s.count", apiName, reason))
+}
+
+func (m *Metrics) IncrementAccessLogSampledOut(apiName string) {
+	m.sink.Increment(fmt.Sprintf("request.api.%s.access_log.sampled_out.count", apiName))
+}
+
+func (m *Metrics) IncrementUpgradedConnections(apiName string) {
+	m.sink.Increment(fmt.Sprintf("request.api.%s.upgraded.count", apiName))
+	m.changeUpgradedConnections(apiName, 1)
+}
+
+func (m *Metrics) DecrementUpgradedConnections(apiName string) {
+	m.changeUpgradedConnections(apiName, -1)
+}
+
+func (m *Metrics) ActiveUpgradedConnections(apiName string) int {
+	m.upgradedMu.Lock()
+	defer m.upgradedMu.Unlock()
+
+	return m.upgraded[apiName]
+}
+
+func (m *Metrics) changeUpgradedConnections(apiName string, delta int) {
+	m.upgradedMu.Lock()
+	defer m.upgradedMu.Unlock()
+
+	m.upgraded[apiName] += delta
+
+	// sent under the lock so gauges reach the sink in the order they changed
+	m.sink.Gauge(fmt.Sprintf("request.api.%s.upgraded.active", apiName), m.upgraded[apiName])
+}
+
+func (m *Metrics) TimeTotalLatency(timing Timing) {
+	m.sink.Timing("request.time.total", time.Since(timing.start))
+}
+
+func (m *Metrics) TimeAPILatency(apiName string, timing Timing) {
+	m.sink.Timing(fmt.Sprintf("request.api.%s.time.total", apiName), time.Since(timing.start))
+}
+
+func (m *Metrics) TimeAPIBackendLatency(apiName, backendName string, timing Timing) {
+	m.sink.Timing(fmt.Sprintf("request.api.%s.backend.%s.time.total", apiName, backendName), time.Since(timing.start))
+}
+
+type discardSink struct{}
+
+func (discardSink) Increment(bucket string)                      {}
+func (discardSink) Gauge(bucket string, value int)               {}
+func (discardSink) Timing(bucket string, duration time.Duration) {}
@@ -21,7 +21,7 @@ func InitNewRelic() newrelic.Application {
 	if cfg.Enabled {
 		app, err := newrelic.NewApplication(cfg)
 		if err != nil {
-			log.Fatalf(err.Error())
+			log.Fatalf("InitNewRelic: %s", err)
 		}
 
 		newRelicApp = app
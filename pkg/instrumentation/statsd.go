@@ -2,7 +2,7 @@ package instrumentation
 
 import (
 	"fmt"
-	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/gojektech/weaver/config"
@@ -10,109 +10,84 @@ import (
 	statsd "gopkg.in/alexcesaro/statsd.v2"
 )
 
-var statsD *statsd.Client
+// statsD holds the *statsd.Client in use, swapped when the configuration is reloaded
+var statsD atomic.Value
 
 func InitiateStatsDMetrics() error {
-	statsDConfig := config.StatsD()
-
-	if statsDConfig.Enabled() {
-		flushPeriod := time.Duration(statsDConfig.FlushPeriodInSeconds()) * time.Second
-		address := fmt.Sprintf("%s:%d", statsDConfig.Host(), statsDConfig.Port())
-
-		var err error
-		statsD, err = statsd.New(statsd.Address(address),
-			statsd.Prefix(statsDConfig.Prefix()), statsd.FlushPeriod(flushPeriod))
-
-		if err != nil {
-			logger.Errorf("StatsD: Error initiating client %s", err)
-			return err
-		}
-
-		logger.Infof("StatsD: Sending metrics")
+	client, err := newStatsDClient()
+	if err != nil {
+		return err
 	}
 
+	statsD.Store(client)
 	return nil
 }
 
-func StatsDClient() *statsd.Client {
-	return statsD
-}
-
-func CloseStatsDClient() {
-	if statsD != nil {
-		logger.Infof("StatsD: Shutting down")
-		statsD.Close()
+// ReloadStatsDMetrics - Replaces the statsd client with one created from the current configuration
+func ReloadStatsDMetrics() error {
+	client, err := newStatsDClient()
+	if err != nil {
+		return err
 	}
-}
 
-func NewTiming() statsd.Timing {
-	if statsD != nil {
-		return statsD.NewTiming()
+	previous, _ := statsD.Swap(client).(*statsd.Client)
+	if previous != nil {
+		previous.Close()
 	}
 
-	return statsd.Timing{}
-}
-
-func IncrementTotalRequestCount() {
-	incrementProbe("request.total.count")
-}
-
-func IncrementAPIRequestCount(apiName string) {
-	incrementProbe(fmt.Sprintf("request.api.%s.count", apiName))
+	return nil
 }
 
-func IncrementAPIStatusCount(apiName string, httpStatusCode int) {
-	incrementProbe(fmt.Sprintf("request.api.%s.status.%d.count", apiName, httpStatusCode))
-}
+func newStatsDClient() (*statsd.Client, error) {
+	statsDConfig := config.StatsD()
+	if !statsDConfig.Enabled() {
+		return nil, nil
+	}
 
-func IncrementAPIBackendRequestCount(apiName, backendName string) {
-	incrementProbe(fmt.Sprintf("request.api.%s.backend.%s.count", apiName, backendName))
-}
+	flushPeriod := time.Duration(statsDConfig.FlushPeriodInSeconds()) * time.Second
+	address := fmt.Sprintf("%s:%d", statsDConfig.Host(), statsDConfig.Port())
 
-func IncrementAPIBackendStatusCount(apiName, backendName string, httpStatusCode int) {
-	incrementProbe(fmt.Sprintf("request.api.%s.backend.%s.status.%d.count", apiName, backendName, httpStatusCode))
-}
+	client, err := statsd.New(statsd.Address(address),
+		statsd.Prefix(statsDConfig.Prefix()), statsd.FlushPeriod(flushPeriod))
 
-func IncrementCrashCount() {
-	incrementProbe("request.internal.crash.count")
-}
+	if err != nil {
+		logger.Errorf("StatsD: Error initiating client %s", err)
+		return nil, err
+	}
 
-func IncrementNotFound() {
-	incrementProbe(fmt.Sprintf("request.internal.%d.count", http.StatusNotFound))
+	logger.Infof("StatsD: Sending metrics")
+	return client, nil
 }
 
-func IncrementInternalAPIStatusCount(aclName string, statusCode int) {
-	incrementProbe(fmt.Sprintf("request.api.%s.internal.status.%d.count", aclName, statusCode))
+func StatsDClient() *statsd.Client {
+	client, _ := statsD.Load().(*statsd.Client)
+	return client
 }
 
-func TimeTotalLatency(timing statsd.Timing) {
-	if statsD != nil {
-		timing.Send("request.time.total")
+func CloseStatsDClient() {
+	if client := StatsDClient(); client != nil {
+		logger.Infof("StatsD: Shutting down")
+		client.Close()
 	}
-
-	return
 }
 
-func TimeAPILatency(apiName string, timing statsd.Timing) {
-	if statsD != nil {
-		timing.Send(fmt.Sprintf("request.api.%s.time.total", apiName))
-	}
+// statsDSink sends metrics to the statsd client in use, dropping them when statsd is disabled
+type statsDSink struct{}
 
-	return
+func (statsDSink) Increment(bucket string) {
+	if client := StatsDClient(); client != nil {
+		go client.Increment(bucket)
+	}
 }
 
-func TimeAPIBackendLatency(apiName, backendName string, timing statsd.Timing) {
-	if statsD != nil {
-		timing.Send(fmt.Sprintf("request.api.%s.backend.%s.time.total", apiName, backendName))
+func (statsDSink) Gauge(bucket string, value int) {
+	if client := StatsDClient(); client != nil {
+		client.Gauge(bucket, value)
 	}
-
-	return
 }
 
-func incrementProbe(key string) {
-	if statsD == nil {
-		return
+func (statsDSink) Timing(bucket string, duration time.Duration) {
+	if client := StatsDClient(); client != nil {
+		client.Timing(bucket, int(duration/time.Millisecond))
 	}
-
-	go statsD.Increment(key)
 }
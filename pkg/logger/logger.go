@@ -1,15 +1,23 @@
 package logger
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/gojektech/weaver/config"
-	"github.com/gojektech/weaver/pkg/util"
+	"github.com/gojektech/weaver/pkg/redact"
+	"github.com/gojektech/weaver/pkg/requestid"
 	"github.com/sirupsen/logrus"
 )
 
-var logger *logrus.Logger
+var logger = New(logrus.WarnLevel)
+
+type ctxKey struct{}
+
+type ctxLevelKey struct{}
 
 func SetupLogger() {
 	level, err := logrus.ParseLevel(config.LogLevel())
@@ -17,7 +25,12 @@ func SetupLogger() {
 		level = logrus.WarnLevel
 	}
 
-	logger = &logrus.Logger{
+	logger = New(level)
+}
+
+// New - Creates a logger writing JSON entries from the level to stdout
+func New(level logrus.Level) *logrus.Logger {
+	return &logrus.Logger{
 		Out:       os.Stdout,
 		Hooks:     make(logrus.LevelHooks),
 		Level:     level,
@@ -25,6 +38,97 @@ func SetupLogger() {
 	}
 }
 
+// NewContext - Returns a copy of ctx carrying the logger entries about its request are written to
+func NewContext(ctx context.Context, l *logrus.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// fromContext is the logger carried by ctx, the logger set up from LOGGER_LEVEL when there is none
+func fromContext(ctx context.Context) *logrus.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*logrus.Logger); ok && l != nil {
+		return l
+	}
+
+	return logger
+}
+
+// NewLevelContext - Returns a copy of ctx whose request logs are written from the level instead of
+// the level of its logger
+func NewLevelContext(ctx context.Context, level logrus.Level) context.Context {
+	return context.WithValue(ctx, ctxLevelKey{}, level)
+}
+
+type levelKey struct {
+	logger *logrus.Logger
+	level  logrus.Level
+}
+
+var (
+	// leveled holds the loggers derived for a request level, one per logger and level, and writers
+	// the writer shared by the loggers derived from one logger
+	leveled   sync.Map
+	derivedMu sync.Mutex
+	writers   = map[*logrus.Logger]*syncWriter{}
+)
+
+// syncWriter serializes the writes of the loggers derived from one logger, each of them locks a
+// mutex of its own
+type syncWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	return sw.out.Write(p)
+}
+
+// requestLogger is the logger carried by ctx, or the one derived from it for the level of the
+// context when it has one
+func requestLogger(ctx context.Context) *logrus.Logger {
+	l := fromContext(ctx)
+
+	level, ok := ctx.Value(ctxLevelKey{}).(logrus.Level)
+	if !ok {
+		return l
+	}
+
+	key := levelKey{logger: l, level: level}
+	if derived, found := leveled.Load(key); found {
+		return derived.(*logrus.Logger)
+	}
+
+	derivedMu.Lock()
+	defer derivedMu.Unlock()
+
+	if derived, found := leveled.Load(key); found {
+		return derived.(*logrus.Logger)
+	}
+
+	out, found := writers[l]
+	if !found {
+		out = &syncWriter{out: l.Out}
+		writers[l] = out
+	}
+
+	derived := &logrus.Logger{Out: out, Hooks: l.Hooks, Formatter: l.Formatter, Level: level}
+	leveled.Store(key, derived)
+	return derived
+}
+
+// SetLevel - Changes the level of the running logger, leaving it unchanged when the level is invalid
+func SetLevel(level string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	logger.SetLevel(parsed)
+	return nil
+}
+
 func AddHook(hook logrus.Hook) {
 	logger.Hooks.Add(hook)
 }
@@ -97,47 +201,6 @@ func InfoWithFieldsf(fields logrus.Fields, format string, args ...interface{}) {
 	logger.WithFields(fields).Infof(format, args...)
 }
 
-func ProxyInfo(aclName string, downstreamHost string, r *http.Request, responseStatus int, rw http.ResponseWriter) {
-	logger.WithFields(logrus.Fields{
-		"type":            "proxy",
-		"downstream_host": downstreamHost,
-		"api_name":        aclName,
-		"request":         httpRequestFields(r),
-		"response":        httpResponseFields(responseStatus, rw),
-	}).Info("proxy")
-}
-
-func httpRequestFields(r *http.Request) logrus.Fields {
-	requestHeaders := map[string]string{}
-	for k := range r.Header {
-		normalizedKey := util.ToSnake(k)
-		if normalizedKey == "authorization" {
-			continue
-		}
-
-		requestHeaders[normalizedKey] = r.Header.Get(k)
-
-	}
-	return logrus.Fields{
-		"uri":     r.URL.String(),
-		"query":   r.URL.Query(),
-		"method":  r.Method,
-		"headers": requestHeaders,
-	}
-}
-
-func httpResponseFields(responseStatus int, rw http.ResponseWriter) logrus.Fields {
-	responseHeaders := map[string]string{}
-	for k := range rw.Header() {
-		responseHeaders[util.ToSnake(k)] = rw.Header().Get(k)
-
-	}
-	return logrus.Fields{
-		"status":  responseStatus,
-		"headers": responseHeaders,
-	}
-}
-
 func Warn(args ...interface{}) {
 	logger.Warn(args...)
 }
@@ -159,9 +222,15 @@ func WithFields(fields logrus.Fields) *logrus.Entry {
 }
 
 func httpRequestLogEntry(r *http.Request) *logrus.Entry {
-	return logger.WithFields(logrus.Fields{
+	fields := logrus.Fields{
 		"request_method": r.Method,
 		"request_host":   r.Host,
-		"request_url":    r.URL.String(),
-	})
+		"request_url":    redact.FromContext(r.Context()).URL(r.URL.String()),
+	}
+
+	if id := requestid.FromContext(r.Context()); id != "" {
+		fields["request_id"] = id
+	}
+
+	return requestLogger(r.Context()).WithFields(fields)
 }
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLoggerIsDerivedOncePerLoggerAndLevel(t *testing.T) {
+	var logs bytes.Buffer
+	l := New(logrus.FatalLevel)
+	l.Out = &logs
+
+	ctx := NewContext(context.Background(), l)
+	assert.Equal(t, l, requestLogger(ctx))
+
+	debug := requestLogger(NewLevelContext(ctx, logrus.DebugLevel))
+	assert.Equal(t, logrus.DebugLevel, debug.Level)
+	assert.True(t, debug == requestLogger(NewLevelContext(ctx, logrus.DebugLevel)))
+
+	errorLogger := requestLogger(NewLevelContext(ctx, logrus.ErrorLevel))
+	assert.Equal(t, logrus.ErrorLevel, errorLogger.Level)
+	assert.True(t, debug.Out == errorLogger.Out)
+
+	errorLogger.Error("written")
+	debug.Debug("also written")
+	assert.Contains(t, logs.String(), "written")
+	assert.Contains(t, logs.String(), "also written")
+}
@@ -0,0 +1,250 @@
+package matcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/savaki/jq"
+)
+
+// ErrBodyTooLarge is returned when evaluating a shard expr needs more of the request body than
+// the matcher is allowed to buffer.
+var ErrBodyTooLarge = errors.New("request body exceeds max buffered size")
+
+var reBodyIndex = regexp.MustCompile(`^\[\s*(\d+)\s*\]$`)
+
+type bodySegment struct {
+	key   string
+	index int
+}
+
+func (seg bodySegment) isIndex() bool {
+	return seg.index >= 0
+}
+
+// parseBodySegments turns a jq style selector into segments that can be walked on a token stream.
+// Selectors using anything other than object keys and array indexes (e.g. ranges) are not streamable.
+func parseBodySegments(expr string) ([]bodySegment, bool) {
+	var segments []bodySegment
+
+	for _, part := range strings.Split(expr, ".") {
+		key := strings.TrimSpace(part)
+		if key == "" {
+			continue
+		}
+
+		if strings.HasPrefix(key, "[") {
+			match := reBodyIndex.FindStringSubmatch(key)
+			if match == nil {
+				return nil, false
+			}
+
+			index, err := strconv.Atoi(match[1])
+			if err != nil {
+				return nil, false
+			}
+
+			segments = append(segments, bodySegment{index: index})
+			continue
+		}
+
+		segments = append(segments, bodySegment{key: key, index: -1})
+	}
+
+	return segments, true
+}
+
+func newBodyMatcher(expr string, opts Options) (MatcherFunc, error) {
+	op, err := jq.Parse(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse shard expr: %s", expr)
+	}
+
+	segments, streamable := parseBodySegments(expr)
+
+	return func(req *http.Request) (string, error) {
+		if req.Body == nil {
+			return "", fmt.Errorf("no request body for expr: %s", expr)
+		}
+
+		body := req.Body
+		consumed := &bytes.Buffer{}
+		reader := io.TeeReader(newLimitedBodyReader(body, opts.MaxBodyBytes), consumed)
+
+		defer func() {
+			req.Body = &replayBody{
+				Reader: io.MultiReader(bytes.NewReader(consumed.Bytes()), body),
+				Closer: body,
+			}
+		}()
+
+		var bodyKey interface{}
+		if streamable {
+			var err error
+			bodyKey, err = findBodyKey(json.NewDecoder(reader), segments)
+			if err != nil {
+				return "", wrapBodyErr(err, "failed to apply parsed shard expr: %s", expr)
+			}
+		} else {
+			requestBody, err := ioutil.ReadAll(reader)
+			if err != nil {
+				return "", wrapBodyErr(err, "failed to read request body for expr: %s", expr)
+			}
+
+			key, err := op.Apply(requestBody)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to apply parsed shard expr: %s", expr)
+			}
+
+			if err := json.Unmarshal(key, &bodyKey); err != nil {
+				return "", errors.Wrapf(err, "failed to unmarshal data for shard expr: %s", expr)
+			}
+		}
+
+		switch v := bodyKey.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		default:
+			return "", errors.New("failed to type assert bodyKey")
+		}
+	}, nil
+}
+
+// findBodyKey walks the token stream down segments and decodes the value found there, leaving
+// everything after it unread.
+func findBodyKey(dec *json.Decoder, segments []bodySegment) (interface{}, error) {
+	if len(segments) == 0 {
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		return value, nil
+	}
+
+	seg := segments[0]
+
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if seg.isIndex() {
+		if token != json.Delim('[') {
+			return nil, fmt.Errorf("expected array for index [%d], found: %v", seg.index, token)
+		}
+
+		for idx := 0; dec.More(); idx++ {
+			if idx == seg.index {
+				return findBodyKey(dec, segments[1:])
+			}
+
+			if err := skipBodyValue(dec); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, fmt.Errorf("index [%d] out of range", seg.index)
+	}
+
+	if token != json.Delim('{') {
+		return nil, fmt.Errorf("expected object for key %s, found: %v", seg.key, token)
+	}
+
+	for dec.More() {
+		keyToken, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		if keyToken == seg.key {
+			return findBodyKey(dec, segments[1:])
+		}
+
+		if err := skipBodyValue(dec); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("key %s not found", seg.key)
+}
+
+func skipBodyValue(dec *json.Decoder) error {
+	depth := 0
+
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func wrapBodyErr(err error, format string, args ...interface{}) error {
+	if err == ErrBodyTooLarge {
+		return err
+	}
+
+	return errors.Wrapf(err, format, args...)
+}
+
+// limitedBodyReader fails with ErrBodyTooLarge instead of io.EOF once more than max bytes have
+// been read, so that a truncated body is never mistaken for a complete one. It reads one byte past
+// max to tell a body of exactly max bytes apart from a larger one.
+type limitedBodyReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func newLimitedBodyReader(reader io.Reader, max int64) io.Reader {
+	if max <= 0 {
+		return reader
+	}
+
+	return &limitedBodyReader{reader: reader, remaining: max + 1}
+}
+
+func (lr *limitedBodyReader) Read(p []byte) (int, error) {
+	if lr.remaining <= 0 {
+		return 0, ErrBodyTooLarge
+	}
+
+	if int64(len(p)) > lr.remaining {
+		p = p[:lr.remaining]
+	}
+
+	n, err := lr.reader.Read(p)
+	lr.remaining -= int64(n)
+
+	if lr.remaining <= 0 {
+		return n, ErrBodyTooLarge
+	}
+
+	return n, err
+}
+
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
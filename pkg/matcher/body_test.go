@@ -0,0 +1,112 @@
+package matcher
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.read += n
+	return n, err
+}
+
+func largeBody(prefix string, padding int) string {
+	return prefix + `, "padding": "` + strings.Repeat("x", padding) + `" }`
+}
+
+func TestBodyMatcherStopsReadingOnceKeyIsFound(t *testing.T) {
+	body := largeBody(`{ "drivers": { "id": "123" }`, 1<<20)
+	reader := &countingReader{Reader: strings.NewReader(body)}
+
+	req := httptest.NewRequest("POST", "/drivers", reader)
+
+	matcherFunc, err := New("body", ".drivers.id", Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
+	require.NoError(t, err, "should not have failed to match a key")
+
+	assert.Equal(t, "123", key)
+	assert.True(t, reader.read < len(body), "should not have read the whole body, read %d bytes", reader.read)
+}
+
+func TestBodyMatcherPreservesBodyForBackend(t *testing.T) {
+	body := largeBody(`{ "drivers": { "id": "123" }`, 64*1024)
+	req := httptest.NewRequest("POST", "/drivers", strings.NewReader(body))
+
+	matcherFunc, err := New("body", ".drivers.id", Options{MaxBodyBytes: 1024})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	_, err = matcherFunc(req)
+	require.NoError(t, err, "should not have failed to match a key")
+
+	forwarded, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err, "should not have failed to read the forwarded body")
+
+	assert.Equal(t, body, string(forwarded))
+}
+
+func TestBodyMatcherSkipsNestedValuesBeforeKey(t *testing.T) {
+	body := `{ "meta": { "tags": ["a", {"b": [1, 2]}] }, "items": [ {"id": 1}, {"id": 2, "serviceType": 7} ] }`
+	req := httptest.NewRequest("POST", "/drivers", strings.NewReader(body))
+
+	matcherFunc, err := New("body", ".items.[1].serviceType", Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
+	require.NoError(t, err, "should not have failed to match a key")
+
+	assert.Equal(t, "7", key)
+}
+
+func TestBodyMatcherFailsWhenKeyIsBeyondMaxBodyBytes(t *testing.T) {
+	body := largeBody(`{ "name": "hello"`, 4096) + `, "drivers": { "id": "123" } }`
+	req := httptest.NewRequest("POST", "/drivers", strings.NewReader(body))
+
+	matcherFunc, err := New("body", ".drivers.id", Options{MaxBodyBytes: 1024})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
+	assert.Equal(t, ErrBodyTooLarge, err)
+	assert.Equal(t, "", key)
+
+	forwarded, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err, "should not have failed to read the forwarded body")
+	assert.Equal(t, body, string(forwarded))
+}
+
+func TestBodyMatcherAllowsBodyOfExactlyMaxBodyBytes(t *testing.T) {
+	body := `{ "drivers": { "id": "123" } }`
+	req := httptest.NewRequest("POST", "/drivers", strings.NewReader(body))
+
+	matcherFunc, err := New("body", ".drivers.id", Options{MaxBodyBytes: int64(len(body))})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "123", key)
+}
+
+func TestBodyMatcherFallsBackToBufferingForRangeExpr(t *testing.T) {
+	body := bytes.NewReader([]byte(`{ "routeRequests": [{ "id": "123" }] }`))
+	req := httptest.NewRequest("POST", "/drivers", body)
+
+	matcherFunc, err := New("body", ".routeRequests.[0:1]", Options{MaxBodyBytes: 8})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	_, err = matcherFunc(req)
+	assert.Equal(t, ErrBodyTooLarge, err)
+}
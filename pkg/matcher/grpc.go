@@ -0,0 +1,74 @@
+package matcher
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// newGRPCMetadataMatcher shards on a gRPC metadata key. Keys ending in -bin carry base64 encoded
+// binary values which are decoded before being used as the shard key.
+func newGRPCMetadataMatcher(expr string, _ Options) (MatcherFunc, error) {
+	key := strings.ToLower(strings.TrimSpace(expr))
+	if key == "" {
+		return nil, errors.New("missing metadata key in shard expr")
+	}
+
+	binary := strings.HasSuffix(key, "-bin")
+
+	return func(req *http.Request) (string, error) {
+		value := req.Header.Get(key)
+		if !binary || value == "" {
+			return value, nil
+		}
+
+		decoded, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to decode binary metadata: %s", key)
+		}
+
+		return string(decoded), nil
+	}, nil
+}
+
+// newGRPCMethodMatcher shards on the called gRPC method: expr is "service", "method" or "full" for
+// the whole /package.Service/Method path, which is the default.
+func newGRPCMethodMatcher(expr string, _ Options) (MatcherFunc, error) {
+	var part func(service, method string) string
+
+	switch expr {
+	case "", "full":
+		part = func(service, method string) string { return "/" + service + "/" + method }
+	case "service":
+		part = func(service, _ string) string { return service }
+	case "method":
+		part = func(_, method string) string { return method }
+	default:
+		return nil, fmt.Errorf("shard expr must be one of service, method or full: %s", expr)
+	}
+
+	return func(req *http.Request) (string, error) {
+		service, method, ok := splitGRPCMethod(req.URL.Path)
+		if !ok {
+			return "", fmt.Errorf("not a gRPC method path: %s", req.URL.Path)
+		}
+
+		return part(service, method), nil
+	}, nil
+}
+
+func splitGRPCMethod(path string) (service, method string, ok bool) {
+	if !strings.HasPrefix(path, "/") {
+		return "", "", false
+	}
+
+	parts := strings.Split(path[1:], "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
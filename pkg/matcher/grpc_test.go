@@ -0,0 +1,75 @@
+package matcher
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGRPCMetadataMatcher(t *testing.T) {
+	req := httptest.NewRequest("POST", "/gojek.drivers.v1.Drivers/Locate", nil)
+	req.Header.Set("X-Driver-Id", "123")
+
+	matcherFunc, err := New("grpc-metadata", "x-driver-id", Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
+	require.NoError(t, err, "should not have failed to match a key")
+
+	assert.Equal(t, "123", key)
+}
+
+func TestGRPCMetadataMatcherDecodesBinaryMetadata(t *testing.T) {
+	req := httptest.NewRequest("POST", "/gojek.drivers.v1.Drivers/Locate", nil)
+	req.Header.Set("X-Driver-Bin", base64.StdEncoding.EncodeToString([]byte("driver-1")))
+
+	matcherFunc, err := New("grpc-metadata", "X-Driver-Bin", Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
+	require.NoError(t, err, "should not have failed to match a key")
+	assert.Equal(t, "driver-1", key)
+
+	req.Header.Set("X-Driver-Bin", "not base64!")
+
+	_, err = matcherFunc(req)
+	assert.Error(t, err, "should have failed to decode the binary metadata")
+}
+
+func TestGRPCMetadataMatcherFailsWithoutKey(t *testing.T) {
+	_, err := New("grpc-metadata", " ", Options{})
+	assert.Error(t, err, "should have failed to compile shard expr")
+}
+
+func TestGRPCMethodMatcher(t *testing.T) {
+	req := httptest.NewRequest("POST", "/gojek.drivers.v1.Drivers/Locate", nil)
+
+	for expr, expected := range map[string]string{
+		"":        "/gojek.drivers.v1.Drivers/Locate",
+		"full":    "/gojek.drivers.v1.Drivers/Locate",
+		"service": "gojek.drivers.v1.Drivers",
+		"method":  "Locate",
+	} {
+		matcherFunc, err := New("grpc-method", expr, Options{})
+		require.NoError(t, err, "should not have failed to compile shard expr")
+
+		key, err := matcherFunc(req)
+		require.NoError(t, err, "should not have failed to match a key")
+
+		assert.Equal(t, expected, key, expr)
+	}
+}
+
+func TestGRPCMethodMatcherFail(t *testing.T) {
+	_, err := New("grpc-method", "package", Options{})
+	assert.Error(t, err, "should have failed to compile shard expr")
+
+	matcherFunc, err := New("grpc-method", "service", Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	_, err = matcherFunc(httptest.NewRequest("POST", "/drivers", nil))
+	assert.Error(t, err, "should have failed to match a non gRPC path")
+}
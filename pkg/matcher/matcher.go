@@ -1,39 +1,58 @@
 package matcher
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
-	"github.com/savaki/jq"
 )
 
-func New(matcherName string) (MatcherFunc, bool) {
-	mf, found := matcherMux[matcherName]
-	return mf, found
+// New compiles shardExpr for the named matcher, so that parse errors surface when an ACL is loaded
+// rather than on every request.
+func New(matcherName string, shardExpr string, opts Options) (MatcherFunc, error) {
+	newMatcher, found := matcherMux[matcherName]
+	if !found {
+		return nil, fmt.Errorf("failed to find a matcher with name '%s'", matcherName)
+	}
+
+	return newMatcher(shardExpr, opts)
+}
+
+// Options - Tunables applied to a matcher when it is compiled
+type Options struct {
+	// MaxBodyBytes caps how much of the request body a matcher may buffer; 0 means no limit.
+	MaxBodyBytes int64
 }
 
-type MatcherFunc func(request *http.Request, shardExpr string) (shardKey string, err error)
+type MatcherFunc func(request *http.Request) (shardKey string, err error)
+
+type matcherGenerator func(shardExpr string, opts Options) (MatcherFunc, error)
 
-var matcherMux = map[string]MatcherFunc{
-	"header": func(req *http.Request, expr string) (string, error) {
+var matcherMux = map[string]matcherGenerator{
+	"header":        newHeaderMatcher,
+	"multi-headers": newMultiHeadersMatcher,
+	"param":         newParamMatcher,
+	"path":          newPathMatcher,
+	"body":          newBodyMatcher,
+	"template":      newTemplateMatcher,
+	"grpc-metadata": newGRPCMetadataMatcher,
+	"grpc-method":   newGRPCMethodMatcher,
+}
+
+func newHeaderMatcher(expr string, _ Options) (MatcherFunc, error) {
+	return func(req *http.Request) (string, error) {
 		return req.Header.Get(expr), nil
-	},
+	}, nil
+}
 
-	"multi-headers": func(req *http.Request, expr string) (string, error) {
-		headers := strings.Split(expr, ",")
-		var headerValues strings.Builder
+func newMultiHeadersMatcher(expr string, _ Options) (MatcherFunc, error) {
+	headers := strings.Split(expr, ",")
+	headersCount := len(headers)
 
-		headersCount := len(headers)
-		if headersCount == 0 {
-			return "", nil
-		}
+	return func(req *http.Request) (string, error) {
+		var headerValues strings.Builder
 
 		for idx, header := range headers {
 			headerValue := req.Header.Get(header)
@@ -48,52 +67,31 @@ var matcherMux = map[string]MatcherFunc{
 		}
 
 		return headerValues.String(), nil
-	},
+	}, nil
+}
 
-	"param": func(req *http.Request, expr string) (string, error) {
+func newParamMatcher(expr string, _ Options) (MatcherFunc, error) {
+	return func(req *http.Request) (string, error) {
 		return req.URL.Query().Get(expr), nil
-	},
+	}, nil
+}
+
+func newPathMatcher(expr string, _ Options) (MatcherFunc, error) {
+	rex, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compile shard expr: %s", expr)
+	}
 
-	"path": func(req *http.Request, expr string) (string, error) {
-		rex := regexp.MustCompile(expr)
+	if rex.NumSubexp() == 0 {
+		return nil, fmt.Errorf("no capture group found in shard expr: %s", expr)
+	}
+
+	return func(req *http.Request) (string, error) {
 		match := rex.FindStringSubmatch(req.URL.Path)
 		if len(match) == 0 {
 			return "", fmt.Errorf("no match found for expr: %s", expr)
 		}
 
 		return match[1], nil
-	},
-
-	"body": func(req *http.Request, expr string) (string, error) {
-		requestBody, err := ioutil.ReadAll(req.Body)
-		if err != nil {
-			return "", errors.Wrapf(err, "failed to read request body for expr: %s", expr)
-		}
-
-		req.Body = ioutil.NopCloser(bytes.NewBuffer(requestBody))
-
-		var bodyKey interface{}
-		op, err := jq.Parse(expr)
-		if err != nil {
-			return "", errors.Wrapf(err, "failed to parse shard expr: %s", expr)
-		}
-
-		key, err := op.Apply(requestBody)
-		if err != nil {
-			return "", errors.Wrapf(err, "failed to apply parsed shard expr: %s", expr)
-		}
-
-		if err := json.Unmarshal(key, &bodyKey); err != nil {
-			return "", errors.Wrapf(err, "failed to unmarshal data for shard expr: %s", expr)
-		}
-
-		switch v := bodyKey.(type) {
-		case string:
-			return v, nil
-		case float64:
-			return strconv.FormatFloat(v, 'f', -1, 64), nil
-		default:
-			return "", errors.New("failed to type assert bodyKey")
-		}
-	},
+	}, nil
 }
@@ -0,0 +1,95 @@
+package matcher
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The *PerRequest benchmarks compile the shard expr inside the loop, which is what every request
+// used to pay for before matchers were compiled at ACL load.
+
+const benchBody = `{ "drivers": { "id": "123", "name": "hello"} }`
+
+func benchmarkPrecompiled(b *testing.B, matcherName, expr string, newRequest func() *http.Request) {
+	matcherFunc, err := New(matcherName, expr, Options{})
+	if err != nil {
+		b.Fatalf("failed to compile shard expr %s: %s", expr, err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		req := newRequest()
+		b.StartTimer()
+
+		if _, err := matcherFunc(req); err != nil {
+			b.Fatalf("failed to match key: %s", err)
+		}
+	}
+}
+
+func benchmarkPerRequest(b *testing.B, matcherName, expr string, newRequest func() *http.Request) {
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		req := newRequest()
+		b.StartTimer()
+
+		matcherFunc, err := New(matcherName, expr, Options{})
+		if err != nil {
+			b.Fatalf("failed to compile shard expr %s: %s", expr, err)
+		}
+
+		if _, err := matcherFunc(req); err != nil {
+			b.Fatalf("failed to match key: %s", err)
+		}
+	}
+}
+
+func newPathRequest() *http.Request {
+	return httptest.NewRequest("GET", "/drivers/123", nil)
+}
+
+func newBodyRequest() *http.Request {
+	return httptest.NewRequest("POST", "/drivers", bytes.NewReader([]byte(benchBody)))
+}
+
+func BenchmarkPathMatcher(b *testing.B) {
+	benchmarkPrecompiled(b, "path", `/drivers/(\d+)`, newPathRequest)
+}
+
+func BenchmarkPathMatcherPerRequest(b *testing.B) {
+	benchmarkPerRequest(b, "path", `/drivers/(\d+)`, newPathRequest)
+}
+
+func BenchmarkBodyMatcher(b *testing.B) {
+	benchmarkPrecompiled(b, "body", ".drivers.id", newBodyRequest)
+}
+
+func BenchmarkBodyMatcherPerRequest(b *testing.B) {
+	benchmarkPerRequest(b, "body", ".drivers.id", newBodyRequest)
+}
+
+func BenchmarkMultiHeadersMatcher(b *testing.B) {
+	benchmarkPrecompiled(b, "multi-headers", "H1,H2,H3", func() *http.Request {
+		req := httptest.NewRequest("GET", "/drivers", nil)
+		req.Header.Add("H1", "One")
+		req.Header.Add("H3", "Three")
+		return req
+	})
+}
+
+func BenchmarkMultiHeadersMatcherPerRequest(b *testing.B) {
+	benchmarkPerRequest(b, "multi-headers", "H1,H2,H3", func() *http.Request {
+		req := httptest.NewRequest("GET", "/drivers", nil)
+		req.Header.Add("H1", "One")
+		req.Header.Add("H3", "Three")
+		return req
+	})
+}
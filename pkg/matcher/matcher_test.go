@@ -15,7 +15,10 @@ func TestBodyMatcher(t *testing.T) {
 	req := httptest.NewRequest("GET", "/drivers", body)
 	expr := ".drivers.id"
 
-	key, err := matcherMux["body"](req, expr)
+	matcherFunc, err := New("body", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to match a key")
 
 	assert.Equal(t, "123", key)
@@ -27,7 +30,10 @@ func TestBodyMatcherParseInt(t *testing.T) {
 	req := httptest.NewRequest("GET", "/drivers", body)
 	expr := ".routeRequests.[0].serviceType"
 
-	key, err := matcherMux["body"](req, expr)
+	matcherFunc, err := New("body", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to match a key")
 
 	assert.Equal(t, "1", key)
@@ -39,7 +45,10 @@ func TestBodyMatcherParseTypeAssertFail(t *testing.T) {
 	req := httptest.NewRequest("GET", "/drivers", body)
 	expr := ".routeRequests.[0].serviceType"
 
-	key, err := matcherMux["body"](req, expr)
+	matcherFunc, err := New("body", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.Error(t, err, "should have failed to match a key")
 	require.Equal(t, "", key)
 
@@ -52,7 +61,10 @@ func TestBodyMatcherFail(t *testing.T) {
 	req := httptest.NewRequest("GET", "/drivers", body)
 	expr := ".drivers.blah"
 
-	key, err := matcherMux["body"](req, expr)
+	matcherFunc, err := New("body", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.Error(t, err, "should have failed to match a key")
 
 	assert.Equal(t, "", key)
@@ -64,7 +76,10 @@ func TestHeaderMatcher(t *testing.T) {
 
 	expr := "Hello"
 
-	key, err := matcherMux["header"](req, expr)
+	matcherFunc, err := New("header", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to match a key")
 
 	assert.Equal(t, "World", key)
@@ -78,7 +93,10 @@ func TestHeadersCsvMatcherWithSingleHeader(t *testing.T) {
 
 	expr := "H2"
 
-	key, err := matcherMux["multi-headers"](req, expr)
+	matcherFunc, err := New("multi-headers", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to extract headers")
 
 	assert.Equal(t, "Two", key)
@@ -88,7 +106,10 @@ func TestHeadersCsvMatcherWithSingleHeaderWhenNoneArePresent(t *testing.T) {
 	req := httptest.NewRequest("GET", "/drivers", nil)
 	expr := "H1"
 
-	key, err := matcherMux["multi-headers"](req, expr)
+	matcherFunc, err := New("multi-headers", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to extract headers")
 
 	assert.Equal(t, "", key)
@@ -102,7 +123,10 @@ func TestHeadersCsvMatcherWithZeroHeaders(t *testing.T) {
 
 	expr := ""
 
-	key, err := matcherMux["multi-headers"](req, expr)
+	matcherFunc, err := New("multi-headers", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to extract headers")
 
 	assert.Equal(t, "", key)
@@ -116,7 +140,10 @@ func TestHeadersCsvMatcherWithMultipleHeaders(t *testing.T) {
 
 	expr := "H1,H3"
 
-	key, err := matcherMux["multi-headers"](req, expr)
+	matcherFunc, err := New("multi-headers", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to extract headers")
 
 	assert.Equal(t, "One,Three", key)
@@ -128,7 +155,10 @@ func TestHeadersCsvMatcherWithMultipleHeadersWhenSomeArePresent(t *testing.T) {
 
 	expr := "H1,H3"
 
-	key, err := matcherMux["multi-headers"](req, expr)
+	matcherFunc, err := New("multi-headers", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to extract headers")
 
 	assert.Equal(t, ",Three", key)
@@ -138,7 +168,10 @@ func TestHeadersCsvMatcherWithMultipleHeadersWhenNoneArePresent(t *testing.T) {
 	req := httptest.NewRequest("GET", "/drivers", nil)
 	expr := "H1,H3"
 
-	key, err := matcherMux["multi-headers"](req, expr)
+	matcherFunc, err := New("multi-headers", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to extract headers")
 
 	assert.Equal(t, ",", key)
@@ -149,7 +182,10 @@ func TestHeaderMatcherFail(t *testing.T) {
 
 	expr := "Hello"
 
-	key, err := matcherMux["header"](req, expr)
+	matcherFunc, err := New("header", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to match a key")
 
 	assert.Equal(t, "", key)
@@ -160,7 +196,10 @@ func TestParamMatcher(t *testing.T) {
 
 	expr := "url"
 
-	key, err := matcherMux["param"](req, expr)
+	matcherFunc, err := New("param", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to match a key")
 
 	assert.Equal(t, "blah", key)
@@ -171,7 +210,10 @@ func TestParamMatcherFail(t *testing.T) {
 
 	expr := "hello"
 
-	key, err := matcherMux["param"](req, expr)
+	matcherFunc, err := New("param", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to match a key")
 
 	assert.Equal(t, "", key)
@@ -182,7 +224,10 @@ func TestPathMatcher(t *testing.T) {
 
 	expr := `/drivers/(\d+)`
 
-	key, err := matcherMux["path"](req, expr)
+	matcherFunc, err := New("path", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
 	require.NoError(t, err, "should not have failed to match a key")
 
 	assert.Equal(t, "123", key)
@@ -191,10 +236,34 @@ func TestPathMatcher(t *testing.T) {
 func TestPathMatcherFail(t *testing.T) {
 	req := httptest.NewRequest("GET", "/drivers/123", nil)
 
-	expr := `/drivers/blah`
+	expr := `/drivers/(blah)`
+
+	matcherFunc, err := New("path", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
 
-	key, err := matcherMux["path"](req, expr)
+	key, err := matcherFunc(req)
 	require.Error(t, err, "should have failed to match a key")
 
 	assert.Equal(t, "", key)
 }
+
+func TestPathMatcherFailsOnInvalidExpr(t *testing.T) {
+	matcherFunc, err := New("path", `/drivers/(\d+`, Options{})
+	require.Error(t, err, "should have failed to compile shard expr")
+
+	assert.Nil(t, matcherFunc)
+}
+
+func TestPathMatcherFailsWithoutCaptureGroup(t *testing.T) {
+	matcherFunc, err := New("path", `/drivers/\d+`, Options{})
+	require.Error(t, err, "should have failed to compile shard expr")
+
+	assert.Nil(t, matcherFunc)
+}
+
+func TestNewFailsForUnknownMatcher(t *testing.T) {
+	matcherFunc, err := New("cookie", "session", Options{})
+	require.Error(t, err, "should have failed to find matcher")
+
+	assert.Nil(t, matcherFunc)
+}
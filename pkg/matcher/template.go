@@ -0,0 +1,240 @@
+package matcher
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"github.com/pkg/errors"
+)
+
+// templateStep evaluates one command of a pipeline; input is the output of the previous command.
+type templateStep func(req *http.Request, input string) (string, error)
+
+type templateFunc struct {
+	args  int
+	piped bool
+	build func(args []string, opts Options) (templateStep, error)
+}
+
+// templateFuncs are the functions available inside a template shard expr. Functions that are not
+// piped read a request attribute and must start a pipeline, the rest transform the piped value,
+// e.g. {{header "X-City" | lower}}:{{body ".order.id" | hash}}.
+var templateFuncs = map[string]templateFunc{
+	"header": {args: 1, build: func(args []string, _ Options) (templateStep, error) {
+		return func(req *http.Request, _ string) (string, error) {
+			return req.Header.Get(args[0]), nil
+		}, nil
+	}},
+
+	"param": {args: 1, build: func(args []string, _ Options) (templateStep, error) {
+		return func(req *http.Request, _ string) (string, error) {
+			return req.URL.Query().Get(args[0]), nil
+		}, nil
+	}},
+
+	"path": {args: 1, build: func(args []string, opts Options) (templateStep, error) {
+		return fromMatcher(newPathMatcher(args[0], opts))
+	}},
+
+	"body": {args: 1, build: func(args []string, opts Options) (templateStep, error) {
+		return fromMatcher(newBodyMatcher(args[0], opts))
+	}},
+
+	"lower": {piped: true, build: func(_ []string, _ Options) (templateStep, error) {
+		return func(_ *http.Request, input string) (string, error) {
+			return strings.ToLower(input), nil
+		}, nil
+	}},
+
+	"upper": {piped: true, build: func(_ []string, _ Options) (templateStep, error) {
+		return func(_ *http.Request, input string) (string, error) {
+			return strings.ToUpper(input), nil
+		}, nil
+	}},
+
+	"hash": {piped: true, build: func(_ []string, _ Options) (templateStep, error) {
+		return func(_ *http.Request, input string) (string, error) {
+			h := fnv.New32a()
+			h.Write([]byte(input))
+			return strconv.FormatUint(uint64(h.Sum32()), 10), nil
+		}, nil
+	}},
+
+	"substr": {args: 2, piped: true, build: func(args []string, _ Options) (templateStep, error) {
+		start, err := strconv.Atoi(args[0])
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid substr start: %s", args[0])
+		}
+
+		end, err := strconv.Atoi(args[1])
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid substr end: %s", args[1])
+		}
+
+		return func(_ *http.Request, input string) (string, error) {
+			if start >= len(input) {
+				return "", nil
+			}
+
+			if end > len(input) {
+				return input[start:], nil
+			}
+
+			return input[start:end], nil
+		}, nil
+	}},
+
+	"regex": {args: 1, piped: true, build: func(args []string, _ Options) (templateStep, error) {
+		rex, err := regexp.Compile(args[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compile regex: %s", args[0])
+		}
+
+		if rex.NumSubexp() == 0 {
+			return nil, fmt.Errorf("no capture group found in regex: %s", args[0])
+		}
+
+		return func(_ *http.Request, input string) (string, error) {
+			match := rex.FindStringSubmatch(input)
+			if len(match) == 0 {
+				return "", fmt.Errorf("no match found for regex: %s", args[0])
+			}
+
+			return match[1], nil
+		}, nil
+	}},
+}
+
+func fromMatcher(matcherFunc MatcherFunc, err error) (templateStep, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return func(req *http.Request, _ string) (string, error) {
+		return matcherFunc(req)
+	}, nil
+}
+
+func newTemplateMatcher(expr string, opts Options) (MatcherFunc, error) {
+	funcNames := make(map[string]interface{}, len(templateFuncs))
+	for name, fn := range templateFuncs {
+		funcNames[name] = fn
+	}
+
+	trees, err := parse.Parse("shard_expr", expr, "{{", "}}", funcNames)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse shard expr: %s", expr)
+	}
+
+	var steps []templateStep
+	for _, node := range trees["shard_expr"].Root.Nodes {
+		step, err := compileTemplateNode(node, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compile shard expr: %s", expr)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return func(req *http.Request) (string, error) {
+		var shardKey strings.Builder
+
+		for _, step := range steps {
+			value, err := step(req, "")
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to evaluate shard expr: %s", expr)
+			}
+
+			shardKey.WriteString(value)
+		}
+
+		return shardKey.String(), nil
+	}, nil
+}
+
+func compileTemplateNode(node parse.Node, opts Options) (templateStep, error) {
+	switch n := node.(type) {
+	case *parse.TextNode:
+		text := string(n.Text)
+		return func(_ *http.Request, _ string) (string, error) {
+			return text, nil
+		}, nil
+	case *parse.ActionNode:
+		return compileTemplatePipe(n.Pipe, opts)
+	default:
+		return nil, fmt.Errorf("unsupported construct: %s", node)
+	}
+}
+
+func compileTemplatePipe(pipe *parse.PipeNode, opts Options) (templateStep, error) {
+	if len(pipe.Decl) != 0 {
+		return nil, fmt.Errorf("variables are not supported: %s", pipe)
+	}
+
+	steps := make([]templateStep, 0, len(pipe.Cmds))
+	for idx, cmd := range pipe.Cmds {
+		ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+		if !ok {
+			return nil, fmt.Errorf("expected a function, found: %s", cmd.Args[0])
+		}
+
+		fn := templateFuncs[ident.Ident]
+		if fn.piped != (idx > 0) {
+			if fn.piped {
+				return nil, fmt.Errorf("%s needs a piped value: %s", ident.Ident, cmd)
+			}
+
+			return nil, fmt.Errorf("%s must start a pipeline: %s", ident.Ident, cmd)
+		}
+
+		args, err := templateLiterals(cmd.Args[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(args) != fn.args {
+			return nil, fmt.Errorf("%s takes %d arguments, found %d: %s", ident.Ident, fn.args, len(args), cmd)
+		}
+
+		step, err := fn.build(args, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, step)
+	}
+
+	return func(req *http.Request, _ string) (string, error) {
+		var value string
+		for _, step := range steps {
+			var err error
+			if value, err = step(req, value); err != nil {
+				return "", err
+			}
+		}
+
+		return value, nil
+	}, nil
+}
+
+func templateLiterals(nodes []parse.Node) ([]string, error) {
+	literals := make([]string, 0, len(nodes))
+
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *parse.StringNode:
+			literals = append(literals, n.Text)
+		case *parse.NumberNode:
+			literals = append(literals, n.Text)
+		default:
+			return nil, fmt.Errorf("arguments must be string or number literals, found: %s", node)
+		}
+	}
+
+	return literals, nil
+}
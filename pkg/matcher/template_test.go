@@ -0,0 +1,87 @@
+package matcher
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateMatcherCombinesRequestAttributes(t *testing.T) {
+	body := strings.NewReader(`{ "order": { "id": 42 } }`)
+	req := httptest.NewRequest("POST", "/orders/AB-99?zone=south", body)
+	req.Header.Add("X-City", "Jakarta")
+
+	expr := `{{header "X-City" | lower}}:{{param "zone"}}:{{body ".order.id"}}:{{path "/orders/([A-Z]+)-"}}`
+
+	matcherFunc, err := New("template", expr, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
+	require.NoError(t, err, "should not have failed to match a key")
+
+	assert.Equal(t, "jakarta:south:42:AB", key)
+}
+
+func TestTemplateMatcherTransforms(t *testing.T) {
+	req := httptest.NewRequest("GET", "/drivers", nil)
+	req.Header.Add("X-Order", "order-12345-id")
+
+	tests := map[string]string{
+		`{{header "X-Order" | upper}}`:                     "ORDER-12345-ID",
+		`{{header "X-Order" | substr 6 11}}`:               "12345",
+		`{{header "X-Order" | substr 6 100}}`:              "12345-id",
+		`{{header "X-Order" | substr 100 200}}`:            "",
+		`{{header "X-Order" | regex "-(\\d+)-"}}`:          "12345",
+		`{{header "X-Order" | regex "-(\\d+)-" | hash}}`:   "1136836824",
+		`shard-{{header "X-Order" | regex "-(\\d+)-"}}-id`: "shard-12345-id",
+	}
+
+	for expr, expected := range tests {
+		matcherFunc, err := New("template", expr, Options{})
+		require.NoError(t, err, "should not have failed to compile shard expr: %s", expr)
+
+		key, err := matcherFunc(req)
+		require.NoError(t, err, "should not have failed to match a key for: %s", expr)
+
+		assert.Equal(t, expected, key, expr)
+	}
+}
+
+func TestTemplateMatcherFailsWhenRegexDoesNotMatch(t *testing.T) {
+	req := httptest.NewRequest("GET", "/drivers", nil)
+	req.Header.Add("X-Order", "order")
+
+	matcherFunc, err := New("template", `{{header "X-Order" | regex "(\\d+)"}}`, Options{})
+	require.NoError(t, err, "should not have failed to compile shard expr")
+
+	key, err := matcherFunc(req)
+	require.Error(t, err, "should have failed to match a key")
+
+	assert.Equal(t, "", key)
+}
+
+func TestTemplateMatcherFailsOnInvalidExpr(t *testing.T) {
+	exprs := []string{
+		`{{header "X-City"`,
+		`{{cookie "session"}}`,
+		`{{lower}}`,
+		`{{header "X-City" | param "zone"}}`,
+		`{{header}}`,
+		`{{header .City}}`,
+		`{{$city := header "X-City"}}`,
+		`{{if header "X-City"}}a{{end}}`,
+		`{{header "X-City" | regex "(\\d+"}}`,
+		`{{header "X-City" | regex "\\d+"}}`,
+		`{{header "X-City" | substr 5 1}}`,
+		`{{path "/drivers/\\d+"}}`,
+	}
+
+	for _, expr := range exprs {
+		matcherFunc, err := New("template", expr, Options{})
+		assert.Error(t, err, "should have failed to compile shard expr: %s", expr)
+		assert.Nil(t, matcherFunc)
+	}
+}
@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gojektech/weaver"
+	"github.com/pkg/errors"
+)
+
+// NewIPAllowlist - Rejects requests from clients outside the configured networks with a 403
+func NewIPAllowlist(data json.RawMessage) (weaver.Plugin, error) {
+	cfg := IPAllowlistConfig{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to parse ip-allowlist config")
+	}
+
+	if len(cfg.CIDRs) == 0 {
+		return nil, errors.New("ip-allowlist needs at least one cidr")
+	}
+
+	allowlist := &IPAllowlist{}
+	for _, cidr := range cfg.CIDRs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cidr in ip-allowlist")
+		}
+
+		allowlist.networks = append(allowlist.networks, network)
+	}
+
+	return allowlist, nil
+}
+
+// IPAllowlistConfig - The networks, in CIDR notation, clients may connect from
+type IPAllowlistConfig struct {
+	CIDRs []string `json:"cidrs"`
+}
+
+type IPAllowlist struct {
+	networks []*net.IPNet
+}
+
+func (al *IPAllowlist) Name() string {
+	return "ip-allowlist"
+}
+
+func (al *IPAllowlist) PostRoute(req *http.Request, acl *weaver.ACL) (*http.Request, error) {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, network := range al.networks {
+			if network.Contains(ip) {
+				return req, nil
+			}
+		}
+	}
+
+	return req, &weaver.PluginError{
+		Status:     http.StatusForbidden,
+		GRPCStatus: weaver.GRPCStatusPermissionDenied,
+		Code:       "weaver:request:forbidden",
+		Message:    fmt.Sprintf("%s is not allowed", host),
+	}
+}
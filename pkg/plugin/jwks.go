@@ -0,0 +1,297 @@
+package plugin
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+// jwksMinRefreshInterval - How often at most the keys are fetched again for a token signed with an
+// unknown key, so a flood of forged tokens does not hammer the identity provider
+const jwksMinRefreshInterval = 10 * time.Second
+
+// jwksMaxBytes - The largest JWKS read from a jwks_url, a key set is a few KB
+const jwksMaxBytes = 1 << 20
+
+var errUnknownKey = errors.New("token is signed with an unknown key")
+
+var jwksClient = &http.Client{Timeout: 5 * time.Second}
+
+// keySet - The public keys of a JWKS, fetched again once older than the ttl or when a token is
+// signed with a key it does not know. Keys are fetched by one request at a time while the others
+// keep using the cached ones.
+type keySet struct {
+	source string
+	fetch  func() ([]byte, error)
+	ttl    time.Duration
+
+	state atomic.Value
+
+	mu       sync.Mutex
+	inflight chan struct{}
+}
+
+// keySetState - The keys last fetched, and the error of the last attempt when it failed
+type keySetState struct {
+	keys        map[string]crypto.PublicKey
+	err         error
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func newFileKeySet(path string, ttl time.Duration) *keySet {
+	return newKeySet(path, ttl, func() ([]byte, error) {
+		return ioutil.ReadFile(path)
+	})
+}
+
+func newURLKeySet(url string, ttl time.Duration) *keySet {
+	return newKeySet(url, ttl, func() ([]byte, error) {
+		res, err := jwksClient.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(res.Body, jwksMaxBytes+1))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(body) > jwksMaxBytes {
+			return nil, fmt.Errorf("jwks is larger than %d bytes", jwksMaxBytes)
+		}
+
+		return body, nil
+	})
+}
+
+func newKeySet(source string, ttl time.Duration, fetch func() ([]byte, error)) *keySet {
+	ks := &keySet{source: source, ttl: ttl, fetch: fetch}
+	ks.state.Store(&keySetState{})
+
+	return ks
+}
+
+func (ks *keySet) current() *keySetState {
+	return ks.state.Load().(*keySetState)
+}
+
+// key returns the key with the kid, the only key of the set when the token names none. A cached key
+// is returned right away, refreshing the keys in the background once they are older than the ttl.
+func (ks *keySet) key(kid string) (crypto.PublicKey, error) {
+	state := ks.current()
+	canRefresh := time.Since(state.attemptedAt) >= jwksMinRefreshInterval
+
+	if key, found := state.lookup(kid); found {
+		if canRefresh && time.Since(state.fetchedAt) >= ks.ttl {
+			ks.startRefresh()
+		}
+
+		return key, nil
+	}
+
+	if canRefresh {
+		<-ks.startRefresh()
+		state = ks.current()
+	}
+
+	if key, found := state.lookup(kid); found {
+		return key, nil
+	}
+
+	if state.err != nil {
+		return nil, state.err
+	}
+
+	return nil, errUnknownKey
+}
+
+// startRefresh fetches the keys unless a fetch is already running, the returned channel is closed
+// once it is done
+func (ks *keySet) startRefresh() <-chan struct{} {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.inflight == nil {
+		done := make(chan struct{})
+		ks.inflight = done
+
+		go func() {
+			if err := ks.refresh(); err != nil {
+				logger.Errorf("failed to refresh jwks from %s: %s", ks.source, err)
+			}
+
+			ks.mu.Lock()
+			ks.inflight = nil
+			ks.mu.Unlock()
+
+			close(done)
+		}()
+	}
+
+	return ks.inflight
+}
+
+// refresh fetches the keys, keys that were valid are kept when it fails until the identity
+// provider answers again
+func (ks *keySet) refresh() error {
+	previous := ks.current()
+	next := &keySetState{keys: previous.keys, fetchedAt: previous.fetchedAt, attemptedAt: time.Now()}
+
+	defer func() { ks.state.Store(next) }()
+
+	data, err := ks.fetch()
+	if err != nil {
+		next.err = errors.Wrapf(err, "failed to fetch jwks from %s", ks.source)
+		return next.err
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		next.err = errors.Wrapf(err, "failed to parse jwks from %s", ks.source)
+		return next.err
+	}
+
+	next.keys, next.fetchedAt = keys, next.attemptedAt
+	return nil
+}
+
+func (state *keySetState) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(state.keys) == 1 {
+		for _, key := range state.keys {
+			return key, true
+		}
+	}
+
+	key, found := state.keys[kid]
+	return key, found
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS reads the RSA and EC signing keys of a JWKS, skipping keys of other types
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecKey()
+		default:
+			continue
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key '%s'", jwk.Kid)
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA or EC signing keys found")
+	}
+
+	return keys, nil
+}
+
+func (jwk jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(jwk.N)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid modulus")
+	}
+
+	e, err := decodeBigInt(jwk.E)
+	if err != nil || !e.IsInt64() {
+		return nil, errors.New("invalid exponent")
+	}
+
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (jwk jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve '%s'", jwk.Crv)
+	}
+
+	x, err := decodeBigInt(jwk.X)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid x coordinate")
+	}
+
+	y, err := decodeBigInt(jwk.Y)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid y coordinate")
+	}
+
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,398 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers the hashes of the signing algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gojektech/weaver"
+	"github.com/gojektech/weaver/pkg/instrumentation"
+	"github.com/pkg/errors"
+)
+
+// NewJWT - Authenticates requests with a bearer JWT signed by a key of the configured JWKS, issued by
+// the issuer for one of the audiences
+func NewJWT(data json.RawMessage) (weaver.Plugin, error) {
+	cfg := JWTConfig{JWKSCacheTTLInMS: 300000}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to parse jwt config")
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	plugin := &JWT{
+		issuer:        cfg.Issuer,
+		audiences:     cfg.Audiences,
+		clockSkew:     time.Duration(cfg.ClockSkewInMS) * time.Millisecond,
+		forwardClaims: cfg.ForwardClaims,
+		now:           time.Now,
+	}
+
+	ttl := time.Duration(cfg.JWKSCacheTTLInMS) * time.Millisecond
+	if cfg.JWKSFile != "" {
+		plugin.keys = newFileKeySet(cfg.JWKSFile, ttl)
+
+		// a file is read right away so an ACL pointing at a missing or invalid one is rejected
+		if err := plugin.keys.refresh(); err != nil {
+			return nil, err
+		}
+	} else {
+		plugin.keys = newURLKeySet(cfg.JWKSURL, ttl)
+	}
+
+	return plugin, nil
+}
+
+// JWTConfig - How bearer JWTs are validated. The keys are read from JWKSFile or fetched from JWKSURL
+// and cached for JWKSCacheTTLInMS. ForwardClaims maps claims to the headers they are sent to the
+// backend in.
+type JWTConfig struct {
+	Issuer           string            `json:"issuer"`
+	Audiences        []string          `json:"audiences"`
+	JWKSFile         string            `json:"jwks_file,omitempty"`
+	JWKSURL          string            `json:"jwks_url,omitempty"`
+	JWKSCacheTTLInMS int64             `json:"jwks_cache_ttl_in_ms,omitempty"`
+	ClockSkewInMS    int64             `json:"clock_skew_in_ms,omitempty"`
+	ForwardClaims    map[string]string `json:"forward_claims,omitempty"`
+}
+
+func (cfg JWTConfig) Validate() error {
+	if cfg.Issuer == "" {
+		return errors.New("jwt needs an issuer")
+	}
+
+	if len(cfg.Audiences) == 0 {
+		return errors.New("jwt needs at least one audience")
+	}
+
+	if (cfg.JWKSFile == "") == (cfg.JWKSURL == "") {
+		return errors.New("jwt needs one of jwks_file or jwks_url")
+	}
+
+	if cfg.JWKSCacheTTLInMS <= 0 {
+		return errors.New("jwt jwks_cache_ttl_in_ms must be positive")
+	}
+
+	if cfg.ClockSkewInMS < 0 {
+		return errors.New("jwt clock_skew_in_ms must not be negative")
+	}
+
+	for claim, header := range cfg.ForwardClaims {
+		if header == "" {
+			return fmt.Errorf("jwt forward_claims has no header for claim '%s'", claim)
+		}
+	}
+
+	return nil
+}
+
+type JWT struct {
+	issuer        string
+	audiences     []string
+	clockSkew     time.Duration
+	forwardClaims map[string]string
+	keys          *keySet
+	now           func() time.Time
+}
+
+func (j *JWT) Name() string {
+	return "jwt"
+}
+
+func (j *JWT) PostRoute(req *http.Request, acl *weaver.ACL) (*http.Request, error) {
+	// claims are only forwarded by weaver, never taken from the client
+	for _, header := range j.forwardClaims {
+		req.Header.Del(header)
+	}
+
+	claims, err := j.authenticate(req)
+	if err != nil {
+		failure, rejected := err.(*authFailure)
+		if !rejected {
+			return req, err
+		}
+
+		instrumentation.MetricsFromContext(req.Context()).IncrementAPIAuthFailureCount(acl.ID, failure.reason)
+		return req, failure.pluginError()
+	}
+
+	for claim, header := range j.forwardClaims {
+		if value, found := claims[claim]; found {
+			req.Header.Set(header, claimHeaderValue(value))
+		}
+	}
+
+	return req, nil
+}
+
+// authenticate returns the claims of the request's token, an *authFailure when the token is
+// rejected and any other error when its keys cannot be loaded
+func (j *JWT) authenticate(req *http.Request) (map[string]interface{}, error) {
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) < len("Bearer ") || !strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return nil, unauthenticated("missing_token", "Bearer token is required")
+	}
+
+	parts := strings.Split(strings.TrimSpace(authorization[len("Bearer "):]), ".")
+	if len(parts) != 3 {
+		return nil, unauthenticated("malformed_token", "Token is malformed")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, unauthenticated("malformed_token", "Token is malformed")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, unauthenticated("malformed_token", "Token is malformed")
+	}
+
+	key, err := j.keys.key(header.Kid)
+	if err == errUnknownKey {
+		return nil, unauthenticated("invalid_signature", "Token is signed with an unknown key")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, unauthenticated("invalid_signature", "Token signature is invalid")
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, unauthenticated("malformed_token", "Token is malformed")
+	}
+
+	if err := j.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (j *JWT) verifyClaims(claims map[string]interface{}) error {
+	now := j.now()
+
+	exp, found := numericDate(claims["exp"])
+	if !found {
+		return unauthenticated("malformed_token", "Token has no expiry")
+	}
+
+	if now.After(exp.Add(j.clockSkew)) {
+		return unauthenticated("expired", "Token is expired")
+	}
+
+	if nbf, found := numericDate(claims["nbf"]); found && now.Before(nbf.Add(-j.clockSkew)) {
+		return unauthenticated("not_yet_valid", "Token is not valid yet")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != j.issuer {
+		return forbidden("invalid_issuer", "Token is not issued by a trusted issuer")
+	}
+
+	if !j.hasAudience(claims["aud"]) {
+		return forbidden("invalid_audience", "Token is not meant for this API")
+	}
+
+	return nil
+}
+
+// hasAudience tells whether aud, a string or a list of strings, names one of the audiences
+func (j *JWT) hasAudience(aud interface{}) bool {
+	var tokenAudiences []string
+
+	switch value := aud.(type) {
+	case string:
+		tokenAudiences = []string{value}
+	case []interface{}:
+		for _, audience := range value {
+			if audience, ok := audience.(string); ok {
+				tokenAudiences = append(tokenAudiences, audience)
+			}
+		}
+	}
+
+	for _, tokenAudience := range tokenAudiences {
+		for _, audience := range j.audiences {
+			if tokenAudience == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+var signingHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// verifySignature checks the signature of the signing input with the key, which must be of the
+// type the algorithm expects
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	hash, found := signingHashes[alg]
+	if !found {
+		return fmt.Errorf("unsupported algorithm '%s'", alg)
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(signingInput))
+	digest := hasher.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key is not an RSA key")
+		}
+
+		return rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature)
+	case "PS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("key is not an RSA key")
+		}
+
+		return rsa.VerifyPSS(rsaKey, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	default:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("key is not an EC key")
+		}
+
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size || ecKey.Curve.Params().BitSize != ecdsaBitSizes[alg] {
+			return errors.New("signature does not match the key")
+		}
+
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return errors.New("signature is invalid")
+		}
+
+		return nil
+	}
+}
+
+var ecdsaBitSizes = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
+
+func numericDate(value interface{}) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), true
+}
+
+// claimHeaderValue renders a claim as a header value, lists of strings comma separated and other
+// structured claims as JSON
+func claimHeaderValue(value interface{}) string {
+	switch claim := value.(type) {
+	case string:
+		return claim
+	case json.Number:
+		return claim.String()
+	case []interface{}:
+		values := make([]string, 0, len(claim))
+		for _, item := range claim {
+			item, ok := item.(string)
+			if !ok {
+				break
+			}
+
+			values = append(values, item)
+		}
+
+		if len(values) == len(claim) {
+			return strings.Join(values, ",")
+		}
+	}
+
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// authFailure - Why a token was rejected, reason being the metric it is counted in
+type authFailure struct {
+	status  int
+	reason  string
+	message string
+}
+
+func unauthenticated(reason, message string) *authFailure {
+	return &authFailure{status: http.StatusUnauthorized, reason: reason, message: message}
+}
+
+func forbidden(reason, message string) *authFailure {
+	return &authFailure{status: http.StatusForbidden, reason: reason, message: message}
+}
+
+func (af *authFailure) Error() string {
+	return af.message
+}
+
+func (af *authFailure) pluginError() *weaver.PluginError {
+	if af.status == http.StatusForbidden {
+		return &weaver.PluginError{
+			Status:     http.StatusForbidden,
+			GRPCStatus: weaver.GRPCStatusPermissionDenied,
+			Code:       "weaver:auth:forbidden",
+			Message:    af.message,
+			Header:     http.Header{"Www-Authenticate": {`Bearer error="insufficient_scope"`}},
+		}
+	}
+
+	challenge := `Bearer error="invalid_token"`
+	if af.reason == "missing_token" {
+		challenge = "Bearer"
+	}
+
+	return &weaver.PluginError{
+		Status:     http.StatusUnauthorized,
+		GRPCStatus: weaver.GRPCStatusUnauthenticated,
+		Code:       "weaver:auth:unauthenticated",
+		Message:    af.message,
+		Header:     http.Header{"Www-Authenticate": {challenge}},
+	}
+}
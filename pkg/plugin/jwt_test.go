@@ -0,0 +1,385 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojektech/weaver"
+	"github.com/gojektech/weaver/pkg/instrumentation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingSink struct {
+	buckets []string
+}
+
+func (cs *countingSink) Increment(bucket string)                      { cs.buckets = append(cs.buckets, bucket) }
+func (cs *countingSink) Gauge(bucket string, value int)               {}
+func (cs *countingSink) Timing(bucket string, duration time.Duration) {}
+
+type testSigner struct {
+	kid    string
+	alg    string
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+func newRSASigner(t *testing.T, kid string) testSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return testSigner{kid: kid, alg: "RS256", rsaKey: key}
+}
+
+func newECSigner(t *testing.T, kid string) testSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return testSigner{kid: kid, alg: "ES256", ecKey: key}
+}
+
+func encodeBigInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func (ts testSigner) jwk() map[string]string {
+	if ts.rsaKey != nil {
+		return map[string]string{
+			"kty": "RSA", "kid": ts.kid, "use": "sig",
+			"n": encodeBigInt(ts.rsaKey.N), "e": encodeBigInt(big.NewInt(int64(ts.rsaKey.E))),
+		}
+	}
+
+	return map[string]string{
+		"kty": "EC", "kid": ts.kid, "crv": "P-256",
+		"x": encodeBigInt(ts.ecKey.X), "y": encodeBigInt(ts.ecKey.Y),
+	}
+}
+
+func jwks(t *testing.T, signers ...testSigner) []byte {
+	keys := []map[string]string{}
+	for _, signer := range signers {
+		keys = append(keys, signer.jwk())
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+
+	return data
+}
+
+func (ts testSigner) sign(t *testing.T, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": ts.alg, "kid": ts.kid, "typ": "JWT"})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	if ts.rsaKey != nil {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, ts.rsaKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	} else {
+		r, s, err := ecdsa.Sign(rand.Reader, ts.ecKey, digest[:])
+		require.NoError(t, err)
+
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   "https://id.example.com",
+		"aud":   []string{"payments", "orders"},
+		"sub":   "customer-42",
+		"roles": []string{"admin", "ops"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func writeJWKS(t *testing.T, data []byte) string {
+	dir, err := ioutil.TempDir("", "weaver-jwks")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "jwks.json")
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	return path
+}
+
+func newTestJWT(t *testing.T, source string) *JWT {
+	plugin, err := NewJWT(json.RawMessage(fmt.Sprintf(`{
+		"issuer": "https://id.example.com",
+		"audiences": ["orders"],
+		%s,
+		"forward_claims": {"sub": "X-User-Id", "roles": "X-User-Roles"}
+	}`, source)))
+	require.NoError(t, err)
+
+	return plugin.(*JWT)
+}
+
+func authenticatedRequest(token string) (*http.Request, *countingSink) {
+	sink := &countingSink{}
+
+	req := httptest.NewRequest("GET", "/orders", nil)
+	req = req.WithContext(instrumentation.NewMetricsContext(req.Context(), instrumentation.NewMetrics(sink)))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req, sink
+}
+
+func TestJWTAcceptsValidTokensAndForwardsClaims(t *testing.T) {
+	rsaSigner, ecSigner := newRSASigner(t, "rsa-1"), newECSigner(t, "ec-1")
+
+	path := writeJWKS(t, jwks(t, rsaSigner, ecSigner))
+	defer os.RemoveAll(filepath.Dir(path))
+
+	plugin := newTestJWT(t, fmt.Sprintf(`"jwks_file": "%s"`, path))
+
+	for _, signer := range []testSigner{rsaSigner, ecSigner} {
+		req, sink := authenticatedRequest(signer.sign(t, validClaims()))
+		req.Header.Set("X-User-Id", "spoofed")
+
+		req, err := plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+		require.NoError(t, err, signer.alg)
+
+		assert.Equal(t, "customer-42", req.Header.Get("X-User-Id"), signer.alg)
+		assert.Equal(t, "admin,ops", req.Header.Get("X-User-Roles"), signer.alg)
+		assert.Empty(t, sink.buckets)
+	}
+}
+
+func TestJWTRejectsInvalidTokens(t *testing.T) {
+	signer, stranger := newRSASigner(t, "rsa-1"), newRSASigner(t, "rsa-1")
+
+	path := writeJWKS(t, jwks(t, signer))
+	defer os.RemoveAll(filepath.Dir(path))
+
+	plugin := newTestJWT(t, fmt.Sprintf(`"jwks_file": "%s"`, path))
+
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+
+		return claims
+	}
+
+	// the claims of one token with the signature of another
+	signed, forged := strings.Split(signer.sign(t, validClaims()), "."), strings.Split(signer.sign(t, withClaim("sub", "admin")), ".")
+	tamperedToken := strings.Join([]string{signed[0], forged[1], signed[2]}, ".")
+
+	for name, test := range map[string]struct {
+		token  string
+		status int
+		reason string
+	}{
+		"missing":        {"", http.StatusUnauthorized, "missing_token"},
+		"malformed":      {"not.a-token", http.StatusUnauthorized, "malformed_token"},
+		"tampered":       {tamperedToken, http.StatusUnauthorized, "invalid_signature"},
+		"foreign key":    {stranger.sign(t, validClaims()), http.StatusUnauthorized, "invalid_signature"},
+		"no expiry":      {signer.sign(t, withClaim("exp", nil)), http.StatusUnauthorized, "malformed_token"},
+		"expired":        {signer.sign(t, withClaim("exp", time.Now().Add(-time.Minute).Unix())), http.StatusUnauthorized, "expired"},
+		"not yet valid":  {signer.sign(t, withClaim("nbf", time.Now().Add(time.Minute).Unix())), http.StatusUnauthorized, "not_yet_valid"},
+		"wrong issuer":   {signer.sign(t, withClaim("iss", "https://evil.example.com")), http.StatusForbidden, "invalid_issuer"},
+		"wrong audience": {signer.sign(t, withClaim("aud", "payments")), http.StatusForbidden, "invalid_audience"},
+	} {
+		req, sink := authenticatedRequest(test.token)
+
+		_, err := plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+		require.IsType(t, &weaver.PluginError{}, err, name)
+
+		assert.Equal(t, test.status, err.(*weaver.PluginError).Status, name)
+		assert.NotEmpty(t, err.(*weaver.PluginError).Header.Get("WWW-Authenticate"), name)
+		assert.Equal(t, []string{"request.api.orders.auth." + test.reason + ".count"}, sink.buckets, name)
+	}
+}
+
+func TestJWTToleratesClockSkew(t *testing.T) {
+	signer := newECSigner(t, "ec-1")
+
+	path := writeJWKS(t, jwks(t, signer))
+	defer os.RemoveAll(filepath.Dir(path))
+
+	plugin := newTestJWT(t, fmt.Sprintf(`"jwks_file": "%s", "clock_skew_in_ms": 60000`, path))
+
+	claims := validClaims()
+	claims["exp"] = time.Now().Add(-30 * time.Second).Unix()
+
+	req, _ := authenticatedRequest(signer.sign(t, claims))
+	_, err := plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+	assert.NoError(t, err)
+}
+
+func TestJWTCachesKeysFetchedFromURL(t *testing.T) {
+	signer, rotated := newRSASigner(t, "rsa-1"), newRSASigner(t, "rsa-2")
+
+	var fetches int32
+	var keys atomic.Value
+	keys.Store(jwks(t, signer))
+
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write(keys.Load().([]byte))
+	}))
+	defer idp.Close()
+
+	plugin := newTestJWT(t, fmt.Sprintf(`"jwks_url": "%s"`, idp.URL))
+
+	for i := 0; i < 3; i++ {
+		req, _ := authenticatedRequest(signer.sign(t, validClaims()))
+		_, err := plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "should have cached the keys")
+
+	keys.Store(jwks(t, signer, rotated))
+
+	req, _ := authenticatedRequest(rotated.sign(t, validClaims()))
+	_, err := plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+	require.IsType(t, &weaver.PluginError{}, err, "should not have refetched keys right after fetching them")
+
+	agedState := *plugin.keys.current()
+	agedState.attemptedAt = time.Now().Add(-jwksMinRefreshInterval)
+	plugin.keys.state.Store(&agedState)
+
+	req, _ = authenticatedRequest(rotated.sign(t, validClaims()))
+	_, err = plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+	require.NoError(t, err, "should have refetched keys for an unknown key")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches))
+}
+
+func TestJWTFailsWhenKeysCannotBeFetched(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer idp.Close()
+
+	plugin := newTestJWT(t, fmt.Sprintf(`"jwks_url": "%s"`, idp.URL))
+
+	req, sink := authenticatedRequest(newRSASigner(t, "rsa-1").sign(t, validClaims()))
+	_, err := plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+
+	require.Error(t, err)
+	_, rejected := err.(*weaver.PluginError)
+	assert.False(t, rejected, "should have failed instead of rejecting the token")
+	assert.Contains(t, err.Error(), "unexpected status 502")
+	assert.Empty(t, sink.buckets)
+}
+
+func TestJWTFailsWhenFetchedKeysAreTooLarge(t *testing.T) {
+	signer := newRSASigner(t, "rsa-1")
+
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := jwks(t, signer)
+		w.Write(keys[:len(keys)-1])
+		w.Write(bytes.Repeat([]byte(" "), jwksMaxBytes))
+		w.Write(keys[len(keys)-1:])
+	}))
+	defer idp.Close()
+
+	plugin := newTestJWT(t, fmt.Sprintf(`"jwks_url": "%s"`, idp.URL))
+
+	req, _ := authenticatedRequest(signer.sign(t, validClaims()))
+	_, err := plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("jwks is larger than %d bytes", jwksMaxBytes))
+}
+
+func TestNewJWTValidatesConfig(t *testing.T) {
+	path := writeJWKS(t, []byte(`{"keys": []}`))
+	defer os.RemoveAll(filepath.Dir(path))
+
+	for config, expectedErr := range map[string]string{
+		`{"audiences": ["orders"], "jwks_url": "http://idp"}`:                                        "jwt needs an issuer",
+		`{"issuer": "idp", "jwks_url": "http://idp"}`:                                                "jwt needs at least one audience",
+		`{"issuer": "idp", "audiences": ["orders"]}`:                                                 "jwt needs one of jwks_file or jwks_url",
+		`{"issuer": "idp", "audiences": ["orders"], "jwks_url": "http://idp", "jwks_file": "/jwks"}`: "jwt needs one of jwks_file or jwks_url",
+		`{"issuer": "idp", "audiences": ["orders"], "jwks_file": "` + path + `"}`:                    "failed to parse jwks from " + path + ": no RSA or EC signing keys found",
+	} {
+		_, err := NewJWT(json.RawMessage(config))
+		assert.EqualError(t, err, expectedErr, config)
+	}
+}
+
+func TestJWTServesCachedKeysWhileRefreshing(t *testing.T) {
+	signer := newRSASigner(t, "rsa-1")
+
+	var fetches int32
+	unblock := make(chan struct{})
+
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-unblock
+		}
+
+		w.Write(jwks(t, signer))
+	}))
+	defer idp.Close()
+	defer close(unblock)
+
+	plugin := newTestJWT(t, fmt.Sprintf(`"jwks_url": "%s"`, idp.URL))
+
+	req, _ := authenticatedRequest(signer.sign(t, validClaims()))
+	_, err := plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+	require.NoError(t, err)
+
+	expiredState := *plugin.keys.current()
+	expiredState.fetchedAt = time.Now().Add(-time.Hour)
+	expiredState.attemptedAt = expiredState.fetchedAt
+	plugin.keys.state.Store(&expiredState)
+
+	for i := 0; i < 3; i++ {
+		done := make(chan error, 1)
+		go func() {
+			req, _ := authenticatedRequest(signer.sign(t, validClaims()))
+			_, err := plugin.PostRoute(req, &weaver.ACL{ID: "orders"})
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("should not have waited for the keys to be fetched again")
+		}
+	}
+
+	for i := 0; i < 100 && atomic.LoadInt32(&fetches) < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "should have fetched the expired keys once")
+}
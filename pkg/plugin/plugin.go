@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/gojektech/weaver"
+	"github.com/pkg/errors"
+)
+
+// Generator - Builds a plugin from the configuration an ACL gives it
+type Generator func(json.RawMessage) (weaver.Plugin, error)
+
+var (
+	pluginTableMu sync.RWMutex
+	pluginTable   = map[string]Generator{
+		"ip-allowlist":    NewIPAllowlist,
+		"jwt":             NewJWT,
+		"require-headers": NewRequireHeaders,
+	}
+)
+
+// Register - Makes a plugin available to ACLs under name, replacing the one registered before
+func Register(name string, generator Generator) {
+	pluginTableMu.Lock()
+	defer pluginTableMu.Unlock()
+
+	pluginTable[name] = generator
+}
+
+// New - Builds the plugin registered under name
+func New(name string, cfg json.RawMessage) (weaver.Plugin, error) {
+	pluginTableMu.RLock()
+	newPlugin, found := pluginTable[name]
+	pluginTableMu.RUnlock()
+
+	if !found {
+		return nil, fmt.Errorf("failed to find plugin with name '%s'", name)
+	}
+
+	return newPlugin(cfg)
+}
+
+// NewChain - Builds the plugins referenced by an ACL, in order
+func NewChain(configs []weaver.PluginConfig) (weaver.PluginChain, error) {
+	chain := make(weaver.PluginChain, 0, len(configs))
+
+	for _, cfg := range configs {
+		plugin, err := New(cfg.Name, cfg.Config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to initialize plugin '%s'", cfg.Name)
+		}
+
+		chain = append(chain, plugin)
+	}
+
+	return chain, nil
+}
@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gojektech/weaver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type namedPlugin string
+
+func (np namedPlugin) Name() string {
+	return string(np)
+}
+
+func TestNewFailsForUnknownPlugin(t *testing.T) {
+	_, err := New("unknown", nil)
+	assert.EqualError(t, err, "failed to find plugin with name 'unknown'")
+}
+
+func TestNewChainBuildsPluginsInOrder(t *testing.T) {
+	Register("named", func(cfg json.RawMessage) (weaver.Plugin, error) {
+		return namedPlugin(cfg), nil
+	})
+
+	chain, err := NewChain([]weaver.PluginConfig{
+		{Name: "named", Config: json.RawMessage(`"first"`)},
+		{Name: "require-headers", Config: json.RawMessage(`{"headers": ["X-Api-Key"]}`)},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, chain, 2)
+	assert.Equal(t, `"first"`, chain[0].Name())
+	assert.Equal(t, "require-headers", chain[1].Name())
+}
+
+func TestNewChainFailsWhenAPluginFails(t *testing.T) {
+	_, err := NewChain([]weaver.PluginConfig{{Name: "ip-allowlist", Config: json.RawMessage(`{"cidrs": ["10.0.0.0"]}`)}})
+	assert.Contains(t, err.Error(), "failed to initialize plugin 'ip-allowlist'")
+}
+
+func TestIPAllowlist(t *testing.T) {
+	allowlist, err := NewIPAllowlist(json.RawMessage(`{"cidrs": ["10.0.0.0/8", "::1/128"]}`))
+	require.NoError(t, err)
+
+	for remoteAddr, allowed := range map[string]bool{
+		"10.1.2.3:4567":   true,
+		"[::1]:4567":      true,
+		"192.168.0.1:123": false,
+		"garbage":         false,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+
+		_, err := allowlist.(weaver.PostRoutePlugin).PostRoute(req, &weaver.ACL{})
+		if allowed {
+			assert.NoError(t, err, remoteAddr)
+			continue
+		}
+
+		require.IsType(t, &weaver.PluginError{}, err, remoteAddr)
+		assert.Equal(t, http.StatusForbidden, err.(*weaver.PluginError).Status)
+		assert.Equal(t, "weaver:request:forbidden", err.(*weaver.PluginError).Code)
+	}
+}
+
+func TestIPAllowlistNeedsCIDRs(t *testing.T) {
+	_, err := NewIPAllowlist(json.RawMessage(`{}`))
+	assert.EqualError(t, err, "ip-allowlist needs at least one cidr")
+}
+
+func TestRequireHeaders(t *testing.T) {
+	requireHeaders, err := NewRequireHeaders(json.RawMessage(`{"headers": ["X-Api-Key"]}`))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	_, err = requireHeaders.(weaver.PostRoutePlugin).PostRoute(req, &weaver.ACL{})
+	require.IsType(t, &weaver.PluginError{}, err)
+	assert.Equal(t, http.StatusBadRequest, err.(*weaver.PluginError).Status)
+	assert.Equal(t, "X-Api-Key header is required", err.(*weaver.PluginError).Message)
+
+	req.Header.Set("X-Api-Key", "key")
+	_, err = requireHeaders.(weaver.PostRoutePlugin).PostRoute(req, &weaver.ACL{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gojektech/weaver"
+	"github.com/pkg/errors"
+)
+
+// NewRequireHeaders - Rejects requests missing any of the configured headers with a 400
+func NewRequireHeaders(data json.RawMessage) (weaver.Plugin, error) {
+	cfg := RequireHeadersConfig{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to parse require-headers config")
+	}
+
+	if len(cfg.Headers) == 0 {
+		return nil, errors.New("require-headers needs at least one header")
+	}
+
+	return &RequireHeaders{headers: cfg.Headers}, nil
+}
+
+// RequireHeadersConfig - The headers every request must have
+type RequireHeadersConfig struct {
+	Headers []string `json:"headers"`
+}
+
+type RequireHeaders struct {
+	headers []string
+}
+
+func (rh *RequireHeaders) Name() string {
+	return "require-headers"
+}
+
+func (rh *RequireHeaders) PostRoute(req *http.Request, acl *weaver.ACL) (*http.Request, error) {
+	for _, name := range rh.headers {
+		if req.Header.Get(name) == "" {
+			return req, &weaver.PluginError{
+				Status:     http.StatusBadRequest,
+				GRPCStatus: weaver.GRPCStatusInvalidArgument,
+				Code:       "weaver:request:missing_header",
+				Message:    fmt.Sprintf("%s header is required", name),
+			}
+		}
+	}
+
+	return req, nil
+}
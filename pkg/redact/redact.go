@@ -0,0 +1,194 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gojektech/weaver/config"
+)
+
+// Mask - Replaces redacted header and query parameter values
+const Mask = "[REDACTED]"
+
+// Policy - What is masked when requests are logged. Headers in DenyHeaders, or missing from
+// AllowHeaders when it is set, are masked, as are query parameters whose name matches one of the
+// QueryParams regular expressions.
+type Policy struct {
+	DenyHeaders  []string `json:"deny_headers,omitempty"`
+	AllowHeaders []string `json:"allow_headers,omitempty"`
+	QueryParams  []string `json:"query_params,omitempty"`
+
+	compiled bool
+	deny     map[string]bool
+	allow    map[string]bool
+	query    []*regexp.Regexp
+}
+
+type ctxKey struct{}
+
+var defaultPolicy = mustCompile(&Policy{DenyHeaders: []string{"Authorization"}})
+
+// Setup - Sets the default policy from the REDACT_* configuration
+func Setup() error {
+	cfg := config.Redaction()
+
+	policy := &Policy{DenyHeaders: cfg.DenyHeaders(), AllowHeaders: cfg.AllowHeaders(), QueryParams: cfg.QueryParams()}
+	if err := policy.Compile(); err != nil {
+		return err
+	}
+
+	defaultPolicy = policy
+	return nil
+}
+
+// Default - The policy applied to requests not routed to an ACL with a policy of its own
+func Default() *Policy {
+	return defaultPolicy
+}
+
+// NewContext - Returns a copy of ctx carrying the policy requests are logged with
+func NewContext(ctx context.Context, policy *Policy) context.Context {
+	return context.WithValue(ctx, ctxKey{}, policy)
+}
+
+// FromContext - The policy carried by ctx, the default policy when there is none
+func FromContext(ctx context.Context) *Policy {
+	if policy, ok := ctx.Value(ctxKey{}).(*Policy); ok && policy != nil {
+		return policy
+	}
+
+	return defaultPolicy
+}
+
+// Compile - Validates the policy and prepares it for use, nil is a valid empty policy
+func (p *Policy) Compile() error {
+	if p == nil {
+		return nil
+	}
+
+	p.deny = headerSet(p.DenyHeaders)
+	p.allow = headerSet(p.AllowHeaders)
+	p.query = nil
+
+	for _, expr := range p.QueryParams {
+		rex, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid query param expr %s: %s", expr, err)
+		}
+
+		p.query = append(p.query, rex)
+	}
+
+	p.compiled = true
+	return nil
+}
+
+// Override - The policy with an ACL's policy applied on top: its denied headers and query params are
+// masked too, and its allowlist replaces the policy's when set
+func (p *Policy) Override(override *Policy) *Policy {
+	if override == nil {
+		return p
+	}
+
+	if !override.compiled {
+		compiled := *override
+		if err := compiled.Compile(); err != nil {
+			return p
+		}
+
+		override = &compiled
+	}
+
+	merged := &Policy{compiled: true, deny: map[string]bool{}, allow: p.allow}
+	for _, policy := range []*Policy{p, override} {
+		for header := range policy.deny {
+			merged.deny[header] = true
+		}
+
+		merged.query = append(merged.query, policy.query...)
+	}
+
+	if len(override.allow) > 0 {
+		merged.allow = override.allow
+	}
+
+	return merged
+}
+
+// Header - The value of the header as it may be logged
+func (p *Policy) Header(name, value string) string {
+	name = http.CanonicalHeaderKey(name)
+	if p.deny[name] || (len(p.allow) > 0 && !p.allow[name]) {
+		return Mask
+	}
+
+	return value
+}
+
+// URL - The URL, or request URI, with the values of redacted query parameters masked. Other
+// parameters are kept as they were encoded.
+func (p *Policy) URL(rawURL string) string {
+	if len(p.query) == 0 {
+		return rawURL
+	}
+
+	queryStart := strings.IndexByte(rawURL, '?')
+	if queryStart < 0 {
+		return rawURL
+	}
+
+	base, query, fragment := rawURL[:queryStart], rawURL[queryStart+1:], ""
+	if fragmentStart := strings.IndexByte(query, '#'); fragmentStart >= 0 {
+		query, fragment = query[:fragmentStart], query[fragmentStart:]
+	}
+
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		rawName := param
+		if separator := strings.IndexByte(param, '='); separator >= 0 {
+			rawName = param[:separator]
+		}
+
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+
+		if p.masksQueryParam(name) {
+			params[i] = rawName + "=" + Mask
+		}
+	}
+
+	return base + "?" + strings.Join(params, "&") + fragment
+}
+
+func (p *Policy) masksQueryParam(name string) bool {
+	for _, rex := range p.query {
+		if rex.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func headerSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+	}
+
+	return set
+}
+
+func mustCompile(policy *Policy) *Policy {
+	if err := policy.Compile(); err != nil {
+		panic(err)
+	}
+
+	return policy
+}
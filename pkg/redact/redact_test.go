@@ -0,0 +1,73 @@
+package redact
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compiled(t *testing.T, policy *Policy) *Policy {
+	require.NoError(t, policy.Compile())
+	return policy
+}
+
+func TestPolicyMasksDeniedHeaders(t *testing.T) {
+	policy := compiled(t, &Policy{DenyHeaders: []string{"cookie", "X-Api-Key"}})
+
+	assert.Equal(t, Mask, policy.Header("Cookie", "session=1"))
+	assert.Equal(t, Mask, policy.Header("x-api-key", "secret"))
+	assert.Equal(t, "curl", policy.Header("User-Agent", "curl"))
+}
+
+func TestPolicyMasksHeadersMissingFromAllowlist(t *testing.T) {
+	policy := compiled(t, &Policy{AllowHeaders: []string{"User-Agent", "X-Request-Id"}})
+
+	assert.Equal(t, "curl", policy.Header("user-agent", "curl"))
+	assert.Equal(t, Mask, policy.Header("X-Phone", "0812345"))
+}
+
+func TestPolicyMasksQueryParams(t *testing.T) {
+	policy := compiled(t, &Policy{QueryParams: []string{"^(phone|msisdn)$", "token"}})
+
+	assert.Equal(t, "/drivers?phone=[REDACTED]&city=jkt&access_token=[REDACTED]#top",
+		policy.URL("/drivers?phone=%2B62812&city=jkt&access_token=abc#top"))
+	assert.Equal(t, "http://example.com/drivers?msisdn=[REDACTED]&telephone=1", policy.URL("http://example.com/drivers?msisdn=1&telephone=1"))
+	assert.Equal(t, "/drivers", policy.URL("/drivers"))
+	assert.Equal(t, "", policy.URL(""))
+}
+
+func TestPolicyCompileFailsOnInvalidQueryParamExpr(t *testing.T) {
+	policy := &Policy{QueryParams: []string{"(phone"}}
+
+	assert.Error(t, policy.Compile())
+	assert.NoError(t, (*Policy)(nil).Compile())
+}
+
+func TestPolicyOverride(t *testing.T) {
+	global := compiled(t, &Policy{DenyHeaders: []string{"Authorization"}, QueryParams: []string{"^phone$"}})
+	acl := &Policy{DenyHeaders: []string{"X-Driver-Id"}, QueryParams: []string{"^email$"}}
+
+	merged := global.Override(acl)
+
+	assert.Equal(t, Mask, merged.Header("Authorization", "Bearer secret"))
+	assert.Equal(t, Mask, merged.Header("X-Driver-Id", "1"))
+	assert.Equal(t, "curl", merged.Header("User-Agent", "curl"))
+	assert.Equal(t, "/?phone=[REDACTED]&email=[REDACTED]", merged.URL("/?phone=1&email=a@b.c"))
+
+	assert.Equal(t, "1", global.Header("X-Driver-Id", "1"), "should not have changed the global policy")
+	assert.Equal(t, global, global.Override(nil))
+
+	allowing := global.Override(&Policy{AllowHeaders: []string{"User-Agent"}})
+	assert.Equal(t, Mask, allowing.Header("X-City", "jkt"))
+	assert.Equal(t, "curl", allowing.Header("User-Agent", "curl"))
+}
+
+func TestPolicyFromContext(t *testing.T) {
+	policy := compiled(t, &Policy{DenyHeaders: []string{"X-City"}})
+
+	assert.Equal(t, policy, FromContext(NewContext(context.Background(), policy)))
+	assert.Equal(t, Default(), FromContext(context.Background()))
+	assert.Equal(t, Mask, Default().Header("Authorization", "Bearer secret"))
+}
@@ -0,0 +1,89 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Formats of the request IDs weaver generates
+const (
+	FormatUUID = "uuid"
+	FormatULID = "ulid"
+)
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var (
+	reUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	reULID = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Za-hjkmnp-tv-z]{25}$`)
+)
+
+type ctxKey struct{}
+
+// ValidFormat - Tells whether request IDs can be generated in the format
+func ValidFormat(format string) bool {
+	return format == FormatUUID || format == FormatULID
+}
+
+// Valid - Tells whether id is a request ID in the format, IDs sent by clients are only kept when it is
+func Valid(format, id string) bool {
+	if format == FormatULID {
+		return reULID.MatchString(id)
+	}
+
+	return reUUID.MatchString(id)
+}
+
+// New - Generates a request ID, a random (version 4) UUID or a ULID
+func New(format string) string {
+	if format == FormatULID {
+		return newULID(time.Now())
+	}
+
+	return newUUID()
+}
+
+// NewContext - Returns a copy of ctx carrying the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext - The request ID carried by ctx, empty when there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// newULID encodes a 48 bit millisecond timestamp followed by 80 random bits in Crockford's base32,
+// so that IDs sort by the time they were generated
+func newULID(now time.Time) string {
+	var b [16]byte
+	ms := uint64(now.UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint64(b[0:8], ms<<16)
+	rand.Read(b[6:])
+
+	hi, lo := binary.BigEndian.Uint64(b[0:8]), binary.BigEndian.Uint64(b[8:16])
+
+	var id [26]byte
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i] = crockfordBase32[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(id[:])
+}
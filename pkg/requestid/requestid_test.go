@@ -0,0 +1,53 @@
+package requestid
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewGeneratesUUIDs(t *testing.T) {
+	id := New(FormatUUID)
+
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+	assert.NotEqual(t, id, New(FormatUUID))
+}
+
+func TestNewGeneratesULIDs(t *testing.T) {
+	id := New(FormatULID)
+
+	assert.Regexp(t, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`), id)
+	assert.NotEqual(t, id, New(FormatULID))
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid(FormatUUID, New(FormatUUID)))
+	assert.True(t, Valid(FormatUUID, "6BA7B810-9DAD-11D1-80B4-00C04FD430C8"))
+	assert.False(t, Valid(FormatUUID, "req-1"))
+	assert.False(t, Valid(FormatUUID, New(FormatULID)))
+	assert.False(t, Valid(FormatUUID, New(FormatUUID)+"\r\nX-Injected: 1"))
+
+	assert.True(t, Valid(FormatULID, New(FormatULID)))
+	assert.True(t, Valid(FormatULID, "01bmzff6000000000000000000"))
+	assert.False(t, Valid(FormatULID, "81BMZFF6000000000000000000"))
+	assert.False(t, Valid(FormatULID, "01BMZFF600000000000000000U"))
+	assert.False(t, Valid(FormatULID, New(FormatUUID)))
+}
+
+func TestULIDsSortByTime(t *testing.T) {
+	earlier := newULID(time.Unix(1500000000, 0))
+	later := newULID(time.Unix(1500000000, int64(time.Millisecond)))
+
+	assert.Equal(t, "01BMZFF600", earlier[:10])
+	assert.True(t, earlier < later)
+}
+
+func TestRequestIDContext(t *testing.T) {
+	ctx := NewContext(context.Background(), "req-1")
+
+	assert.Equal(t, "req-1", FromContext(ctx))
+	assert.Equal(t, "", FromContext(context.Background()))
+}
@@ -1,6 +1,7 @@
 package shard
 
 import (
+	"crypto/tls"
 	"fmt"
 	"time"
 
@@ -22,9 +23,13 @@ func Error(msg string) error {
 }
 
 type BackendDefinition struct {
-	BackendName string   `json:"backend_name"`
-	BackendURL  string   `json:"backend"`
-	Timeout     *float64 `json:"timeout,omitempty"`
+	BackendName string                `json:"backend_name"`
+	BackendURL  string                `json:"backend"`
+	Timeout     *float64              `json:"timeout,omitempty"`
+	Timeouts    *weaver.TimeoutConfig `json:"timeouts,omitempty"`
+	Rewrite     *weaver.RewriteConfig `json:"rewrite,omitempty"`
+	TLS         *config.UpstreamTLS   `json:"tls,omitempty"`
+	Protocol    string                `json:"protocol,omitempty"`
 }
 
 func (bd BackendDefinition) Validate() error {
@@ -58,16 +63,66 @@ func toBackends(shardConfig map[string]BackendDefinition) (map[string]*weaver.Ba
 	return backends, nil
 }
 
+// backendList - The backends of a strategy, for weaver.BackendLister
+func backendList(backends map[string]*weaver.Backend) []*weaver.Backend {
+	list := make([]*weaver.Backend, 0, len(backends))
+	for _, backend := range backends {
+		list = append(list, backend)
+	}
+
+	return list
+}
+
 func parseBackend(shardConfig BackendDefinition) (*weaver.Backend, error) {
 	timeoutInDuration := config.Proxy().ProxyDialerTimeoutInMS()
 
 	if shardConfig.Timeout != nil {
-		timeoutInDuration = time.Duration(*shardConfig.Timeout)
+		timeoutInDuration = time.Duration(*shardConfig.Timeout) * time.Millisecond
+	}
+
+	rewrite, err := weaver.NewRewrite(shardConfig.Rewrite, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compile rewrite for backend: %s", shardConfig.BackendName)
+	}
+
+	tlsConfig, err := backendTLSConfig(shardConfig)
+	if err != nil {
+		return nil, err
 	}
 
 	backendOptions := weaver.BackendOptions{
-		Timeout: timeoutInDuration * time.Millisecond,
+		Timeout:   timeoutInDuration,
+		Timeouts:  backendTimeouts(shardConfig),
+		Rewrite:   rewrite,
+		TLSConfig: tlsConfig,
+		Protocol:  shardConfig.Protocol,
 	}
 
 	return weaver.NewBackend(shardConfig.BackendName, shardConfig.BackendURL, backendOptions)
 }
+
+func backendTLSConfig(shardConfig BackendDefinition) (*tls.Config, error) {
+	if shardConfig.TLS == nil {
+		return nil, nil
+	}
+
+	tlsConfig, err := shardConfig.TLS.ClientConfig()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to build TLS config for backend: %s", shardConfig.BackendName)
+	}
+
+	return tlsConfig, nil
+}
+
+// backendTimeouts treats the legacy timeout as the backend's connect timeout, so that it still takes
+// precedence over the ACL's timeouts
+func backendTimeouts(shardConfig BackendDefinition) *weaver.TimeoutConfig {
+	if shardConfig.Timeout == nil {
+		return shardConfig.Timeouts
+	}
+
+	legacy := &weaver.TimeoutConfig{ConnectInMS: int64(*shardConfig.Timeout)}
+	timeouts := legacy.Override(shardConfig.Timeouts)
+
+	return &timeouts
+}
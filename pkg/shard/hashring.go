@@ -42,6 +42,10 @@ func (rs HashRingStrategy) Shard(key string) (*weaver.Backend, error) {
 	return rs.backends[serverName], nil
 }
 
+func (rs HashRingStrategy) Backends() []*weaver.Backend {
+	return backendList(rs.backends)
+}
+
 type HashRingStrategyConfig struct {
 	TotalVirtualBackends *int                         `json:"totalVirtualBackends"`
 	Backends             map[string]BackendDefinition `json:"backends"`
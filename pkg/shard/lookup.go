@@ -29,3 +29,7 @@ type LookupStrategy struct {
 func (ls *LookupStrategy) Shard(key string) (*weaver.Backend, error) {
 	return ls.backends[key], nil
 }
+
+func (ls *LookupStrategy) Backends() []*weaver.Backend {
+	return backendList(ls.backends)
+}
@@ -37,3 +37,7 @@ func (ms ModuloStrategy) Shard(key string) (*weaver.Backend, error) {
 	modulo := id % (len(ms.backends))
 	return ms.backends[strconv.Itoa(modulo)], nil
 }
+
+func (ms ModuloStrategy) Backends() []*weaver.Backend {
+	return backendList(ms.backends)
+}
@@ -18,8 +18,7 @@ func NewNoStrategy(data json.RawMessage) (weaver.Sharder, error) {
 		return nil, err
 	}
 
-	backendOptions := weaver.BackendOptions{}
-	backend, err := weaver.NewBackend(cfg.BackendName, cfg.BackendURL, backendOptions)
+	backend, err := parseBackend(cfg.BackendDefinition)
 	if err != nil {
 		return nil, errors.WithStack(fmt.Errorf("failed to create backend: %s: %+v", err, cfg))
 	}
@@ -37,6 +36,10 @@ func (ns *NoStrategy) Shard(key string) (*weaver.Backend, error) {
 	return ns.backend, nil
 }
 
+func (ns *NoStrategy) Backends() []*weaver.Backend {
+	return []*weaver.Backend{ns.backend}
+}
+
 type NoStrategyConfig struct {
 	BackendDefinition `json:",inline"`
 }
@@ -1,8 +1,23 @@
 package shard
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -62,3 +77,87 @@ func TestNoStrategyFailWhenBackendIsNotAValidURL(t *testing.T) {
 
 	assert.Nil(t, noStrategy)
 }
+
+func writeClientCertificate(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "should not have failed to generate key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "weaver"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "should not have failed to create certificate")
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err, "should not have failed to parse certificate")
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err, "should not have failed to marshal key")
+
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return cert, certFile, keyFile
+}
+
+func TestNoStrategyConnectsToBackendWithMutualTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "weaver-upstream-tls")
+	defer os.RemoveAll(dir)
+
+	clientCert, certFile, keyFile := writeClientCertificate(t, dir)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client-CN", r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600))
+
+	shardWithTLS := func(tlsConfig string) *http.Response {
+		shardConfig := json.RawMessage(fmt.Sprintf(`{ "backend_name": "foobar", "backend": "%s", "tls": %s }`, ts.URL, tlsConfig))
+
+		noStrategy, err := NewNoStrategy(shardConfig)
+		require.NoError(t, err, "should not have failed to parse the shard config")
+
+		backend, err := noStrategy.Shard("whatever")
+		require.NoError(t, err, "should not have failed when finding shard")
+
+		w := httptest.NewRecorder()
+		backend.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/drivers", nil))
+
+		return w.Result()
+	}
+
+	response := shardWithTLS(fmt.Sprintf(`{ "cert_file": "%s", "key_file": "%s", "ca_file": "%s", "server_name": "example.com" }`, certFile, keyFile, caFile))
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "weaver", response.Header.Get("X-Client-CN"))
+
+	response = shardWithTLS(fmt.Sprintf(`{ "ca_file": "%s" }`, caFile))
+	assert.Equal(t, http.StatusBadGateway, response.StatusCode, "should have failed without a client certificate")
+
+	response = shardWithTLS(fmt.Sprintf(`{ "cert_file": "%s", "key_file": "%s" }`, certFile, keyFile))
+	assert.Equal(t, http.StatusBadGateway, response.StatusCode, "should have failed to verify the backend without the CA bundle")
+}
+
+func TestNewNoStrategyFailsWhenTLSConfigIsInvalid(t *testing.T) {
+	shardConfig := json.RawMessage(`{ "backend_name": "foobar", "backend": "https://localhost", "tls": { "profile": "unknown" } }`)
+
+	noStrategy, err := NewNoStrategy(shardConfig)
+	require.Error(t, err, "should have failed to build the TLS config")
+
+	assert.Contains(t, err.Error(), "failed to build TLS config for backend: foobar")
+	assert.Nil(t, noStrategy)
+}
@@ -66,3 +66,7 @@ func (pls *PrefixLookupStrategy) Shard(key string) (*weaver.Backend, error) {
 
 	return pls.backends[prefix], nil
 }
+
+func (pls *PrefixLookupStrategy) Backends() []*weaver.Backend {
+	return backendList(pls.backends)
+}
@@ -166,3 +166,7 @@ func (s2s *S2Strategy) Shard(key string) (*weaver.Backend, error) {
 
 	return nil, Error("fail to find backend")
 }
+
+func (s2s *S2Strategy) Backends() []*weaver.Backend {
+	return backendList(s2s.backends)
+}
@@ -0,0 +1,131 @@
+package weaver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// gRPC status codes plugins commonly reject calls with
+const (
+	GRPCStatusInvalidArgument  = 3
+	GRPCStatusPermissionDenied = 7
+	GRPCStatusUnauthenticated  = 16
+)
+
+// Plugin - Cross-cutting behaviour run while proxying a request, at the phases whose interface it
+// implements: PreRoutePlugin, PostRoutePlugin, PostShardPlugin and ResponsePlugin
+type Plugin interface {
+	Name() string
+}
+
+// PreRoutePlugin - Runs before the request is routed, only for plugins installed on the server since
+// the ACL is not known yet
+type PreRoutePlugin interface {
+	Plugin
+	PreRoute(req *http.Request) (*http.Request, error)
+}
+
+// PostRoutePlugin - Runs once the request is routed to an ACL, before it is sharded
+type PostRoutePlugin interface {
+	Plugin
+	PostRoute(req *http.Request, acl *ACL) (*http.Request, error)
+}
+
+// PostShardPlugin - Runs once a backend is chosen, before the request is sent to it
+type PostShardPlugin interface {
+	Plugin
+	PostShard(req *http.Request, route *RequestRoute) (*http.Request, error)
+}
+
+// ResponsePlugin - Runs on the backend's response before it is sent to the client
+type ResponsePlugin interface {
+	Plugin
+	Response(res *http.Response, route *RequestRoute) error
+}
+
+// PluginConfig - A plugin referenced by name from an ACL, with its configuration
+type PluginConfig struct {
+	Name   string          `json:"name"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// PluginError - Stops a request, answered with the weaver error of its status and code. A phase
+// returning any other error is answered with a 500.
+type PluginError struct {
+	Status     int
+	GRPCStatus int
+	Code       string
+	Message    string
+
+	// Header - Headers added to the error response, e.g. WWW-Authenticate
+	Header http.Header
+}
+
+func (pe *PluginError) Error() string {
+	return fmt.Sprintf("%s (%d): %s", pe.Code, pe.Status, pe.Message)
+}
+
+// PluginChain - Plugins run in order at each phase, the first error stops the chain
+type PluginChain []Plugin
+
+// PreRoute - Runs the chain's PreRoutePlugins
+func (chain PluginChain) PreRoute(req *http.Request) (*http.Request, error) {
+	for _, plugin := range chain {
+		if p, ok := plugin.(PreRoutePlugin); ok {
+			next, err := p.PreRoute(req)
+			if err != nil {
+				return req, err
+			}
+
+			req = next
+		}
+	}
+
+	return req, nil
+}
+
+// PostRoute - Runs the chain's PostRoutePlugins
+func (chain PluginChain) PostRoute(req *http.Request, acl *ACL) (*http.Request, error) {
+	for _, plugin := range chain {
+		if p, ok := plugin.(PostRoutePlugin); ok {
+			next, err := p.PostRoute(req, acl)
+			if err != nil {
+				return req, err
+			}
+
+			req = next
+		}
+	}
+
+	return req, nil
+}
+
+// PostShard - Runs the chain's PostShardPlugins
+func (chain PluginChain) PostShard(req *http.Request, route *RequestRoute) (*http.Request, error) {
+	for _, plugin := range chain {
+		if p, ok := plugin.(PostShardPlugin); ok {
+			next, err := p.PostShard(req, route)
+			if err != nil {
+				return req, err
+			}
+
+			req = next
+		}
+	}
+
+	return req, nil
+}
+
+// Response - Runs the chain's ResponsePlugins
+func (chain PluginChain) Response(res *http.Response, route *RequestRoute) error {
+	for _, plugin := range chain {
+		if p, ok := plugin.(ResponsePlugin); ok {
+			if err := p.Response(res, route); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package weaver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPlugin struct {
+	name  string
+	calls *[]string
+	err   error
+}
+
+func (rp recordingPlugin) Name() string {
+	return rp.name
+}
+
+func (rp recordingPlugin) PreRoute(req *http.Request) (*http.Request, error) {
+	*rp.calls = append(*rp.calls, rp.name+":pre-route")
+	return req, rp.err
+}
+
+func (rp recordingPlugin) Response(res *http.Response, route *RequestRoute) error {
+	*rp.calls = append(*rp.calls, rp.name+":response")
+	return rp.err
+}
+
+func TestPluginChainRunsPluginsImplementingThePhase(t *testing.T) {
+	var calls []string
+	chain := PluginChain{recordingPlugin{name: "first", calls: &calls}, recordingPlugin{name: "second", calls: &calls}}
+
+	req := httptest.NewRequest("GET", "/", nil)
+
+	next, err := chain.PreRoute(req)
+	require.NoError(t, err)
+	assert.Equal(t, req, next)
+
+	_, err = chain.PostRoute(req, &ACL{})
+	require.NoError(t, err)
+
+	require.NoError(t, chain.Response(&http.Response{}, &RequestRoute{}))
+
+	assert.Equal(t, []string{"first:pre-route", "second:pre-route", "first:response", "second:response"}, calls)
+}
+
+func TestPluginChainStopsAtTheFirstError(t *testing.T) {
+	var calls []string
+	rejection := &PluginError{Status: http.StatusForbidden, Code: "weaver:request:forbidden"}
+	chain := PluginChain{recordingPlugin{name: "first", calls: &calls, err: rejection}, recordingPlugin{name: "second", calls: &calls}}
+
+	req := httptest.NewRequest("GET", "/", nil)
+
+	next, err := chain.PreRoute(req)
+	assert.Equal(t, rejection, err)
+	assert.Equal(t, req, next)
+
+	assert.Equal(t, rejection, chain.Response(&http.Response{}, &RequestRoute{}))
+
+	assert.Equal(t, []string{"first:pre-route", "first:response"}, calls)
+}
+
+func TestNilPluginChainDoesNothing(t *testing.T) {
+	var chain PluginChain
+
+	_, err := chain.PostShard(httptest.NewRequest("GET", "/", nil), &RequestRoute{})
+	assert.NoError(t, err)
+	assert.NoError(t, chain.Response(&http.Response{}, &RequestRoute{}))
+}
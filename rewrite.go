@@ -0,0 +1,128 @@
+package weaver
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var reCaptureRef = regexp.MustCompile(`\$\{?(\d+)\}?`)
+
+// RewriteConfig - Defines how the request path is rewritten before it is forwarded to a backend.
+// Rules apply in order: strip_prefix, regex/replacement, add_prefix, to the path as sent, so an
+// escaped character such as %2F reaches the backend escaped. A shard_expr used as the regex is
+// written for the unescaped path like the matcher, it is matched against that and re-escaped.
+type RewriteConfig struct {
+	StripPrefix string `json:"strip_prefix,omitempty"`
+	AddPrefix   string `json:"add_prefix,omitempty"`
+	Regex       string `json:"regex,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+type Rewrite struct {
+	stripPrefix string
+	addPrefix   string
+	regex       *regexp.Regexp
+	replacement string
+	unescaped   bool
+}
+
+// NewRewrite - Compiles a RewriteConfig. defaultRegex is used when a replacement is given without
+// a regex, so replacements can refer to capture groups of a path shard_expr.
+func NewRewrite(cfg *RewriteConfig, defaultRegex string) (*Rewrite, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	if cfg.StripPrefix != "" && !strings.HasPrefix(cfg.StripPrefix, "/") {
+		return nil, fmt.Errorf("strip_prefix must start with /: %s", cfg.StripPrefix)
+	}
+
+	if cfg.AddPrefix != "" && !strings.HasPrefix(cfg.AddPrefix, "/") {
+		return nil, fmt.Errorf("add_prefix must start with /: %s", cfg.AddPrefix)
+	}
+
+	rewrite := &Rewrite{
+		stripPrefix: escapePath(strings.TrimSuffix(cfg.StripPrefix, "/")),
+		addPrefix:   escapePath(strings.TrimSuffix(cfg.AddPrefix, "/")),
+		replacement: cfg.Replacement,
+	}
+
+	expr := cfg.Regex
+	if expr == "" && cfg.Replacement != "" {
+		// the shard_expr matches the path before strip_prefix, it would not match the stripped one
+		if defaultRegex != "" && cfg.StripPrefix != "" {
+			return nil, fmt.Errorf("strip_prefix needs a regex matching the stripped path for replacement: %s", cfg.Replacement)
+		}
+
+		expr = defaultRegex
+		rewrite.unescaped = true
+	}
+
+	if expr == "" {
+		if cfg.Replacement != "" {
+			return nil, fmt.Errorf("missing regex for replacement: %s", cfg.Replacement)
+		}
+
+		return rewrite, nil
+	}
+
+	regex, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compile rewrite regex: %s", expr)
+	}
+
+	for _, ref := range reCaptureRef.FindAllStringSubmatch(cfg.Replacement, -1) {
+		group, _ := strconv.Atoi(ref[1])
+		if group > regex.NumSubexp() {
+			return nil, fmt.Errorf("replacement %s refers to missing capture group %d in: %s", cfg.Replacement, group, expr)
+		}
+	}
+
+	rewrite.regex = regex
+	return rewrite, nil
+}
+
+// Apply - Rewrites the path of u in place
+func (rw *Rewrite) Apply(u *url.URL) {
+	if rw == nil {
+		return
+	}
+
+	path := u.EscapedPath()
+
+	if rw.stripPrefix != "" && (path == rw.stripPrefix || strings.HasPrefix(path, rw.stripPrefix+"/")) {
+		path = strings.TrimPrefix(path, rw.stripPrefix)
+	}
+
+	if rw.regex != nil && rw.unescaped {
+		path = escapePath(rw.regex.ReplaceAllString(u.Path, rw.replacement))
+	} else if rw.regex != nil {
+		path = rw.regex.ReplaceAllString(path, rw.replacement)
+	}
+
+	if rw.addPrefix != "" {
+		path = rw.addPrefix + "/" + strings.TrimPrefix(path, "/")
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	unescaped, err := url.PathUnescape(path)
+	if err != nil {
+		// a replacement produced an invalid escape, it is forwarded as it reads
+		u.Path, u.RawPath = path, ""
+		return
+	}
+
+	u.Path, u.RawPath = unescaped, path
+}
+
+func escapePath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
@@ -0,0 +1,96 @@
+package weaver
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var rewriteTests = []struct {
+	cfg          RewriteConfig
+	defaultRegex string
+	path         string
+	expected     string
+}{
+	{RewriteConfig{StripPrefix: "/gojek/hello-service"}, "", "/gojek/hello-service/v1/orders", "/v1/orders"},
+	{RewriteConfig{StripPrefix: "/gojek/hello-service/"}, "", "/gojek/hello-service", "/"},
+	{RewriteConfig{StripPrefix: "/gojek/hello"}, "", "/gojek/hello-service/v1/orders", "/gojek/hello-service/v1/orders"},
+	{RewriteConfig{AddPrefix: "/api"}, "", "/v1/orders", "/api/v1/orders"},
+	{RewriteConfig{StripPrefix: "/gojek", AddPrefix: "/internal/"}, "", "/gojek/orders", "/internal/orders"},
+	{RewriteConfig{Regex: `^/orders/(\d+)/items$`, Replacement: "/items/$1"}, "", "/orders/12/items", "/items/12"},
+	{RewriteConfig{Replacement: "/v2/drivers/${1}"}, `/drivers/(\d+)`, "/drivers/123", "/v2/drivers/123"},
+	{RewriteConfig{StripPrefix: "/svc", Regex: `^/v1/(.*)`, Replacement: "/$1", AddPrefix: "/v2"}, "", "/svc/v1/orders", "/v2/orders"},
+	{RewriteConfig{StripPrefix: "/svc", AddPrefix: "/v2"}, "", "/svc/files/a%2Fb", "/v2/files/a%2Fb"},
+	{RewriteConfig{Regex: `^/files/([^/]+)$`, Replacement: "/blobs/$1"}, "", "/files/a%2Fb", "/blobs/a%2Fb"},
+	{RewriteConfig{StripPrefix: "/my svc"}, "", "/my%20svc/orders", "/orders"},
+	{RewriteConfig{Replacement: "/v2/search/$1"}, `^/search/([a-z ]+)$`, "/search/new%20york", "/v2/search/new%20york"},
+	{RewriteConfig{Replacement: "/v2/search/$1", AddPrefix: "/api"}, `^/search/([a-z ]+)$`, "/search/new%20york", "/api/v2/search/new%20york"},
+}
+
+func TestRewriteApply(t *testing.T) {
+	for _, tt := range rewriteTests {
+		cfg := tt.cfg
+		rewrite, err := NewRewrite(&cfg, tt.defaultRegex)
+		require.NoError(t, err, "should not have failed to compile rewrite: %+v", cfg)
+
+		u, _ := url.Parse(tt.path)
+		rewrite.Apply(u)
+
+		assert.Equal(t, tt.expected, u.EscapedPath(), "%+v", cfg)
+	}
+}
+
+func TestRewriteApplyKeepsEscapedSlashesInOneSegment(t *testing.T) {
+	rewrite, err := NewRewrite(&RewriteConfig{StripPrefix: "/svc"}, "")
+	require.NoError(t, err)
+
+	u, _ := url.Parse("http://backend/svc/files/a%2Fb?x=1")
+	rewrite.Apply(u)
+
+	assert.Equal(t, "/files/a/b", u.Path)
+	assert.Equal(t, "/files/a%2Fb", u.RawPath)
+	assert.Equal(t, "http://backend/files/a%2Fb?x=1", u.String())
+}
+
+func TestNewRewriteFailsWhenStripPrefixIsCombinedWithTheDefaultRegex(t *testing.T) {
+	rewrite, err := NewRewrite(&RewriteConfig{StripPrefix: "/svc", Replacement: "/v2/drivers/$1"}, `/svc/drivers/(\d+)`)
+	assert.Error(t, err)
+	assert.Nil(t, rewrite)
+
+	rewrite, err = NewRewrite(&RewriteConfig{StripPrefix: "/svc", Regex: `^/drivers/(\d+)`, Replacement: "/v2/drivers/$1"}, `/svc/drivers/(\d+)`)
+	require.NoError(t, err)
+
+	u, _ := url.Parse("/svc/drivers/12")
+	rewrite.Apply(u)
+
+	assert.Equal(t, "/v2/drivers/12", u.Path)
+}
+
+func TestNilRewriteLeavesPathUntouched(t *testing.T) {
+	rewrite, err := NewRewrite(nil, "")
+	require.NoError(t, err)
+
+	u, _ := url.Parse("/v1/orders")
+	rewrite.Apply(u)
+
+	assert.Equal(t, "/v1/orders", u.Path)
+}
+
+func TestNewRewriteFailsOnInvalidConfig(t *testing.T) {
+	invalid := []RewriteConfig{
+		{StripPrefix: "gojek"},
+		{AddPrefix: "v1"},
+		{Replacement: "/$1"},
+		{Regex: `/orders/(\d+`, Replacement: "/$1"},
+		{Regex: `/orders/(\d+)`, Replacement: "/$2"},
+	}
+
+	for _, cfg := range invalid {
+		cfg := cfg
+		rewrite, err := NewRewrite(&cfg, "")
+		assert.Error(t, err, "should have failed to compile rewrite: %+v", cfg)
+		assert.Nil(t, rewrite)
+	}
+}
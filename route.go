@@ -0,0 +1,38 @@
+package weaver
+
+import (
+	"context"
+	"net/http"
+)
+
+type routeCtxKey struct{}
+
+// RequestRoute - The ACL, backend and shard key a request was routed with
+type RequestRoute struct {
+	ACL      *ACL
+	Backend  *Backend
+	ShardKey string
+
+	// Plugins - The server's and the ACL's plugins, run on the backend's response
+	Plugins PluginChain
+
+	// UpstreamStatus - The status code the backend answered with, 0 when it did not answer
+	UpstreamStatus int
+
+	// UpstreamErr - Set when the backend could not be reached or timed out, for the server to render
+	UpstreamErr error
+
+	// PluginErr - Set when a plugin rejected the backend's response, for the server to render
+	PluginErr error
+}
+
+// WithRequestRoute - Attaches the route to the request, so the backend handling it can apply ACL behaviour
+func WithRequestRoute(req *http.Request, route *RequestRoute) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), routeCtxKey{}, route))
+}
+
+// RequestRouteFrom - Returns the route attached to a request context, if any
+func RequestRouteFrom(ctx context.Context) (*RequestRoute, bool) {
+	route, ok := ctx.Value(routeCtxKey{}).(*RequestRoute)
+	return route, ok
+}
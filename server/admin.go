@@ -0,0 +1,16 @@
+package server
+
+import "net/http"
+
+func newAdminHandler(certs *certificateStore, h *health) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/health/live", h.liveHandler)
+	mux.HandleFunc("/health/ready", h.readyHandler)
+
+	if certs != nil {
+		mux.Handle("/certificates", certs)
+	}
+
+	return mux
+}
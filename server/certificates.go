@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+type loadedCertificate struct {
+	pair     config.CertificatePair
+	cert     *tls.Certificate
+	leaf     *x509.Certificate
+	modTime  time.Time
+	loadedAt time.Time
+	err      error
+}
+
+// certificateStore serves certificates by SNI and reloads them when their files change on disk
+type certificateStore struct {
+	mu     sync.RWMutex
+	certs  []*loadedCertificate
+	byName map[string]*tls.Certificate
+}
+
+type certificateStatus struct {
+	CertFile  string    `json:"cert_file"`
+	KeyFile   string    `json:"key_file"`
+	Names     []string  `json:"names"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	LoadedAt  time.Time `json:"loaded_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func newCertificateStore(pairs []config.CertificatePair) (*certificateStore, error) {
+	if len(pairs) == 0 {
+		return nil, errors.New("no certificates configured")
+	}
+
+	store := &certificateStore{}
+	for _, pair := range pairs {
+		loaded, err := loadCertificate(pair)
+		if err != nil {
+			return nil, err
+		}
+
+		store.certs = append(store.certs, loaded)
+	}
+
+	store.index()
+	return store, nil
+}
+
+func loadCertificate(pair config.CertificatePair) (*loadedCertificate, error) {
+	modTime, err := certificateModTime(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load certificate %s", pair.CertFile)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse certificate %s", pair.CertFile)
+	}
+
+	return &loadedCertificate{
+		pair:     pair,
+		cert:     &cert,
+		leaf:     leaf,
+		modTime:  modTime,
+		loadedAt: time.Now(),
+	}, nil
+}
+
+func certificateModTime(pair config.CertificatePair) (time.Time, error) {
+	var modTime time.Time
+
+	for _, file := range []string{pair.CertFile, pair.KeyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTime, errors.Wrapf(err, "failed to stat %s", file)
+		}
+
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	return modTime, nil
+}
+
+// index must be called with mu held for writing. The first certificate configured is the default
+// for clients without SNI or with an unknown server name.
+func (cs *certificateStore) index() {
+	cs.byName = map[string]*tls.Certificate{}
+
+	for idx := len(cs.certs) - 1; idx >= 0; idx-- {
+		loaded := cs.certs[idx]
+		for _, name := range certificateNames(loaded.leaf) {
+			cs.byName[strings.ToLower(name)] = loaded.cert
+		}
+	}
+}
+
+func certificateNames(leaf *x509.Certificate) []string {
+	if len(leaf.DNSNames) > 0 {
+		return leaf.DNSNames
+	}
+
+	if leaf.Subject.CommonName != "" {
+		return []string{leaf.Subject.CommonName}
+	}
+
+	return nil
+}
+
+func (cs *certificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if cert, found := cs.byName[name]; found {
+		return cert, nil
+	}
+
+	if idx := strings.Index(name, "."); idx > 0 {
+		if cert, found := cs.byName["*"+name[idx:]]; found {
+			return cert, nil
+		}
+	}
+
+	return cs.certs[0].cert, nil
+}
+
+// reload picks up certificates whose files changed since they were last loaded. A certificate that
+// fails to load keeps serving its previous version and reports the error on the admin endpoint.
+func (cs *certificateStore) reload() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	changed := false
+	for idx, current := range cs.certs {
+		modTime, err := certificateModTime(current.pair)
+		if err == nil && !modTime.After(current.modTime) {
+			continue
+		}
+
+		loaded, err := loadCertificate(current.pair)
+		if err != nil {
+			logger.Errorf("failed to reload certificate %s: %s", current.pair.CertFile, err)
+			current.err = err
+			continue
+		}
+
+		logger.Infof("reloaded certificate %s, expires at %s", loaded.pair.CertFile, loaded.leaf.NotAfter)
+		cs.certs[idx] = loaded
+		changed = true
+	}
+
+	if changed {
+		cs.index()
+	}
+}
+
+func (cs *certificateStore) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cs.reload()
+		}
+	}
+}
+
+func (cs *certificateStore) status() []certificateStatus {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	statuses := make([]certificateStatus, 0, len(cs.certs))
+	for _, loaded := range cs.certs {
+		status := certificateStatus{
+			CertFile:  loaded.pair.CertFile,
+			KeyFile:   loaded.pair.KeyFile,
+			Names:     certificateNames(loaded.leaf),
+			NotBefore: loaded.leaf.NotBefore,
+			NotAfter:  loaded.leaf.NotAfter,
+			LoadedAt:  loaded.loadedAt,
+		}
+
+		if loaded.err != nil {
+			status.Error = loaded.err.Error()
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+func (cs *certificateStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	response, err := json.Marshal(map[string][]certificateStatus{"certificates": cs.status()})
+	if err != nil {
+		internalServerError(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+func newTLSConfig(tlsConfig config.TLSConfig, store *certificateStore) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tlsConfig.MinVersion(),
+		CipherSuites:   tlsConfig.CipherSuites(),
+		GetCertificate: store.GetCertificate,
+	}
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCertificate(t *testing.T, dir, name string, notAfter time.Time, dnsNames ...string) config.CertificatePair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "should not have failed to generate key")
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "should not have failed to create certificate")
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err, "should not have failed to marshal key")
+
+	pair := config.CertificatePair{
+		CertFile: filepath.Join(dir, name+".crt"),
+		KeyFile:  filepath.Join(dir, name+".key"),
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	require.NoError(t, ioutil.WriteFile(pair.CertFile, certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(pair.KeyFile, keyPEM, 0600))
+
+	return pair
+}
+
+func servedName(t *testing.T, store *certificateStore, serverName string) string {
+	cert, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+	require.NoError(t, err, "should not have failed to get a certificate")
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err, "should not have failed to parse certificate")
+
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateStoreSelectsCertificateBySNI(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "weaver-certs")
+	defer os.RemoveAll(dir)
+
+	expiry := time.Now().Add(24 * time.Hour)
+	store, err := newCertificateStore([]config.CertificatePair{
+		writeCertificate(t, dir, "default", expiry, "weaver.local"),
+		writeCertificate(t, dir, "api", expiry, "api.gojek.io"),
+		writeCertificate(t, dir, "wildcard", expiry, "*.golabs.io"),
+	})
+	require.NoError(t, err, "should not have failed to load certificates")
+
+	assert.Equal(t, "api.gojek.io", servedName(t, store, "api.gojek.io"))
+	assert.Equal(t, "api.gojek.io", servedName(t, store, "API.gojek.io."))
+	assert.Equal(t, "*.golabs.io", servedName(t, store, "hello.golabs.io"))
+	assert.Equal(t, "weaver.local", servedName(t, store, "unknown.io"))
+	assert.Equal(t, "weaver.local", servedName(t, store, ""))
+}
+
+func TestCertificateStoreFailsOnMissingCertificate(t *testing.T) {
+	store, err := newCertificateStore([]config.CertificatePair{{CertFile: "/nope.crt", KeyFile: "/nope.key"}})
+	assert.Error(t, err, "should have failed to load certificates")
+	assert.Nil(t, store)
+
+	store, err = newCertificateStore(nil)
+	assert.Error(t, err, "should have failed without certificates")
+	assert.Nil(t, store)
+}
+
+func TestCertificateStoreReloadsChangedCertificates(t *testing.T) {
+	logger.SetupLogger()
+
+	dir, _ := ioutil.TempDir("", "weaver-certs")
+	defer os.RemoveAll(dir)
+
+	pair := writeCertificate(t, dir, "api", time.Now().Add(time.Hour), "api.gojek.io")
+	store, err := newCertificateStore([]config.CertificatePair{pair})
+	require.NoError(t, err, "should not have failed to load certificates")
+
+	renewedExpiry := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	writeCertificate(t, dir, "api", renewedExpiry, "api.gojek.io")
+
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(pair.CertFile, future, future))
+
+	store.reload()
+
+	status := store.status()
+	require.Len(t, status, 1)
+	assert.True(t, renewedExpiry.Equal(status[0].NotAfter), "should have served the renewed certificate")
+	assert.Empty(t, status[0].Error)
+
+	require.NoError(t, ioutil.WriteFile(pair.KeyFile, []byte("garbage"), 0600))
+	later := future.Add(time.Minute)
+	require.NoError(t, os.Chtimes(pair.KeyFile, later, later))
+
+	store.reload()
+
+	status = store.status()
+	assert.True(t, renewedExpiry.Equal(status[0].NotAfter), "should have kept the last good certificate")
+	assert.NotEmpty(t, status[0].Error)
+	assert.Equal(t, "api.gojek.io", servedName(t, store, "api.gojek.io"))
+}
+
+func TestAdminCertificatesEndpoint(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "weaver-certs")
+	defer os.RemoveAll(dir)
+
+	expiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	store, err := newCertificateStore([]config.CertificatePair{
+		writeCertificate(t, dir, "api", expiry, "api.gojek.io", "www.gojek.io"),
+	})
+	require.NoError(t, err, "should not have failed to load certificates")
+
+	w := httptest.NewRecorder()
+	newAdminHandler(store, &health{router: NewRouter(&mockRouteLoader{})}).ServeHTTP(w, httptest.NewRequest("GET", "/certificates", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := map[string][]certificateStatus{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response["certificates"], 1)
+
+	status := response["certificates"][0]
+	assert.Equal(t, []string{"api.gojek.io", "www.gojek.io"}, status.Names)
+	assert.True(t, expiry.Equal(status.NotAfter))
+}
+
+func TestTLSListenerServesCertificateBySNI(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "weaver-certs")
+	defer os.RemoveAll(dir)
+
+	expiry := time.Now().Add(24 * time.Hour)
+	store, err := newCertificateStore([]config.CertificatePair{
+		writeCertificate(t, dir, "default", expiry, "weaver.local"),
+		writeCertificate(t, dir, "api", expiry, "api.gojek.io"),
+	})
+	require.NoError(t, err, "should not have failed to load certificates")
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.TLS = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: store.GetCertificate}
+	ts.StartTLS()
+	defer ts.Close()
+
+	conn, err := tls.Dial("tcp", ts.Listener.Addr().String(), &tls.Config{
+		ServerName:         "api.gojek.io",
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS11,
+	})
+	if conn != nil {
+		conn.Close()
+	}
+	assert.Error(t, err, "should have refused TLS versions below the minimum")
+
+	conn, err = tls.Dial("tcp", ts.Listener.Addr().String(), &tls.Config{
+		ServerName:         "api.gojek.io",
+		InsecureSkipVerify: true,
+	})
+	require.NoError(t, err, "should not have failed the TLS handshake")
+	defer conn.Close()
+
+	assert.Equal(t, "api.gojek.io", conn.ConnectionState().PeerCertificates[0].Subject.CommonName)
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const stringLiteral = "(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)"
+
+var (
+	grpcServiceCriterion = regexp.MustCompile(`GRPCService\(\s*` + stringLiteral + `\s*\)`)
+	grpcMethodCriterion  = regexp.MustCompile(`GRPCMethod\(\s*` + stringLiteral + `\s*,\s*` + stringLiteral + `\s*\)`)
+
+	grpcServiceName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+	grpcMethodName  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+const grpcContentType = "HeaderRegexp(`Content-Type`, `^application/grpc($|[+;])`)"
+
+// expandCriterion rewrites the gRPC criteria GRPCService("pkg.Service") and
+// GRPCMethod("pkg.Service", "Method") into matchers vulcand/route understands
+func expandCriterion(criterion string) (string, error) {
+	var expandErr error
+
+	expanded := grpcMethodCriterion.ReplaceAllStringFunc(criterion, func(call string) string {
+		args := grpcMethodCriterion.FindStringSubmatch(call)
+
+		service, err := unquoteName(args[1], grpcServiceName)
+		if err != nil {
+			expandErr = err
+			return call
+		}
+
+		method, err := unquoteName(args[2], grpcMethodName)
+		if err != nil {
+			expandErr = err
+			return call
+		}
+
+		return fmt.Sprintf("Path(`/%s/%s`) && %s", service, method, grpcContentType)
+	})
+
+	expanded = grpcServiceCriterion.ReplaceAllStringFunc(expanded, func(call string) string {
+		args := grpcServiceCriterion.FindStringSubmatch(call)
+
+		service, err := unquoteName(args[1], grpcServiceName)
+		if err != nil {
+			expandErr = err
+			return call
+		}
+
+		return fmt.Sprintf("PathRegexp(`^/%s/[^/]+$`) && %s", strings.Replace(service, ".", `\.`, -1), grpcContentType)
+	})
+
+	return expanded, expandErr
+}
+
+func unquoteName(literal string, valid *regexp.Regexp) (string, error) {
+	name, err := strconv.Unquote(literal)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s in criterion: %s", literal, err)
+	}
+
+	if !valid.MatchString(name) {
+		return "", fmt.Errorf("invalid gRPC name in criterion: %s", name)
+	}
+
+	return name, nil
+}
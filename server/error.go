@@ -1,14 +1,20 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
+	"github.com/gojektech/weaver"
 	"github.com/gojektech/weaver/pkg/instrumentation"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/gojektech/weaver/pkg/requestid"
+	"github.com/pkg/errors"
 )
 
 type weaverResponse struct {
-	Errors []errorDetails `json:"errors"`
+	Errors    []errorDetails `json:"errors"`
+	RequestID string         `json:"request_id,omitempty"`
 }
 
 type errorDetails struct {
@@ -19,68 +25,229 @@ type errorDetails struct {
 }
 
 func notFoundError(w http.ResponseWriter, r *http.Request) {
-	instrumentation.IncrementNotFound()
-	w.Header().Set("Content-Type", "application/json")
-
-	w.WriteHeader(http.StatusNotFound)
-
-	errorResponse := weaverResponse{
-		Errors: []errorDetails{
-			{
-				Code:            "weaver:route:not_found",
-				Message:         "Something went wrong",
-				MessageTitle:    "Failure",
-				MessageSeverity: "failure",
-			},
-		},
+	instrumentation.MetricsFromContext(r.Context()).IncrementNotFound()
+
+	if weaver.IsGRPCRequest(r) {
+		weaver.WriteGRPCError(w, weaver.GRPCStatusUnimplemented, "weaver:route:not_found")
+		return
 	}
 
-	response, _ := json.Marshal(errorResponse)
-	w.Write(response)
+	writeError(w, r, nil, "", http.StatusNotFound, errorDetails{
+		Code:            "weaver:route:not_found",
+		Message:         "Something went wrong",
+		MessageTitle:    "Failure",
+		MessageSeverity: "failure",
+	})
 }
 
 func internalServerError(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	w.WriteHeader(http.StatusInternalServerError)
-
-	errorResponse := weaverResponse{
-		Errors: []errorDetails{
-			{
-				Code:            "weaver:service:unavailable",
-				Message:         "Something went wrong",
-				MessageTitle:    "Internal error",
-				MessageSeverity: "failure",
-			},
-		},
+	if weaver.IsGRPCRequest(r) {
+		weaver.WriteGRPCError(w, weaver.GRPCStatusInternal, "weaver:service:unavailable")
+		return
+	}
+
+	writeError(w, r, nil, "", http.StatusInternalServerError, errorDetails{
+		Code:            "weaver:service:unavailable",
+		Message:         "Something went wrong",
+		MessageTitle:    "Internal error",
+		MessageSeverity: "failure",
+	})
+}
+
+type errorTemplatesKey struct{}
+
+// withErrorTemplates returns a copy of ctx carrying the error templates of the weaver serving the
+// request, an ACL's own templates take precedence
+func withErrorTemplates(ctx context.Context, templates weaver.ErrorTemplates) context.Context {
+	return context.WithValue(ctx, errorTemplatesKey{}, templates)
+}
+
+func errorTemplatesFrom(ctx context.Context) weaver.ErrorTemplates {
+	templates, _ := ctx.Value(errorTemplatesKey{}).(weaver.ErrorTemplates)
+	return templates
+}
+
+// writeError writes the weaver error response, or the error template of the ACL or the global one
+// for its code
+func writeError(w http.ResponseWriter, r *http.Request, aclTemplates weaver.ErrorTemplates, aclID string, status int, details errorDetails) {
+	requestID := requestid.FromContext(r.Context())
+
+	contentType := "application/json"
+	response, _ := json.Marshal(weaverResponse{Errors: []errorDetails{details}, RequestID: requestID})
+
+	tmpl := aclTemplates.Lookup(details.Code)
+	if tmpl == nil {
+		tmpl = errorTemplatesFrom(r.Context()).Lookup(details.Code)
 	}
 
-	response, _ := json.Marshal(errorResponse)
+	if tmpl != nil {
+		rendered, err := tmpl.Render(r, weaver.ErrorData{
+			ACLID:     aclID,
+			Code:      details.Code,
+			Message:   details.Message,
+			Status:    status,
+			RequestID: requestID,
+		})
+
+		if err != nil {
+			logger.Errorrf(r, "failed to render error template for %s: %s", details.Code, err)
+		} else {
+			for name, values := range rendered.Header {
+				w.Header()[name] = values
+			}
+
+			status, contentType = rendered.Status, rendered.ContentType
+			if rendered.Body != nil {
+				response = rendered.Body
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
 	w.Write(response)
 }
 
 // TODO: decouple instrumentation from this errors function
 type err503Handler struct {
-	ACLName string
+	ACLName   string
+	Templates weaver.ErrorTemplates
 }
 
 func (eh err503Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	failureHTTPStatus := http.StatusServiceUnavailable
-	instrumentation.IncrementInternalAPIStatusCount(eh.ACLName, failureHTTPStatus)
-
-	errorResponse := weaverResponse{
-		Errors: []errorDetails{
-			{
-				Code:            "weaver:service:unavailable",
-				Message:         "Something went wrong",
-				MessageTitle:    "Failure",
-				MessageSeverity: "failure",
-			},
-		},
+	instrumentation.MetricsFromContext(r.Context()).IncrementInternalAPIStatusCount(eh.ACLName, failureHTTPStatus)
+
+	if weaver.IsGRPCRequest(r) {
+		weaver.WriteGRPCError(w, weaver.GRPCStatusUnavailable, "weaver:service:unavailable")
+		return
 	}
 
-	response, _ := json.Marshal(errorResponse)
-	w.WriteHeader(failureHTTPStatus)
-	w.Write(response)
-	return
+	writeError(w, r, eh.Templates, eh.ACLName, failureHTTPStatus, errorDetails{
+		Code:            "weaver:service:unavailable",
+		Message:         "Something went wrong",
+		MessageTitle:    "Failure",
+		MessageSeverity: "failure",
+	})
+}
+
+type err413Handler struct {
+	ACLName   string
+	Templates weaver.ErrorTemplates
+}
+
+func (eh err413Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	failureHTTPStatus := http.StatusRequestEntityTooLarge
+	instrumentation.MetricsFromContext(r.Context()).IncrementInternalAPIStatusCount(eh.ACLName, failureHTTPStatus)
+
+	if weaver.IsGRPCRequest(r) {
+		weaver.WriteGRPCError(w, weaver.GRPCStatusResourceExhausted, "weaver:request:too_large")
+		return
+	}
+
+	writeError(w, r, eh.Templates, eh.ACLName, failureHTTPStatus, errorDetails{
+		Code:            "weaver:request:too_large",
+		Message:         "Request body too large",
+		MessageTitle:    "Failure",
+		MessageSeverity: "failure",
+	})
+}
+
+// statusClientClosedRequest - Non standard status, borrowed from nginx, for requests the client gave up on
+const statusClientClosedRequest = 499
+
+type upstreamFailureResponse struct {
+	httpStatus int
+	grpcStatus int
+	code       string
+	message    string
+}
+
+var upstreamFailureResponses = map[weaver.UpstreamFailure]upstreamFailureResponse{
+	weaver.UpstreamTimeout:         {http.StatusGatewayTimeout, weaver.GRPCStatusDeadlineExceeded, "weaver:upstream:timeout", "Upstream did not respond in time"},
+	weaver.UpstreamUnreachable:     {http.StatusServiceUnavailable, weaver.GRPCStatusUnavailable, "weaver:upstream:unreachable", "Upstream refused the connection"},
+	weaver.UpstreamDNSFailure:      {http.StatusBadGateway, weaver.GRPCStatusUnavailable, "weaver:upstream:dns_failure", "Upstream host could not be resolved"},
+	weaver.UpstreamTLSFailure:      {http.StatusBadGateway, weaver.GRPCStatusUnavailable, "weaver:upstream:tls_failure", "TLS handshake with upstream failed"},
+	weaver.UpstreamConnectionReset: {http.StatusBadGateway, weaver.GRPCStatusUnavailable, "weaver:upstream:connection_reset", "Upstream closed the connection"},
+	weaver.UpstreamError:           {http.StatusBadGateway, weaver.GRPCStatusUnavailable, "weaver:upstream:error", "Something went wrong"},
+	weaver.UpstreamClientCanceled:  {statusClientClosedRequest, weaver.GRPCStatusCanceled, "weaver:request:canceled", "Client closed the request"},
+}
+
+type errUpstreamHandler struct {
+	ACLName     string
+	BackendName string
+	Failure     weaver.UpstreamFailure
+	Templates   weaver.ErrorTemplates
+}
+
+func (eh errUpstreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	failure, found := upstreamFailureResponses[eh.Failure]
+	if !found {
+		failure = upstreamFailureResponses[weaver.UpstreamError]
+	}
+
+	instrumentation.MetricsFromContext(r.Context()).IncrementInternalAPIStatusCount(eh.ACLName, failure.httpStatus)
+	if eh.Failure != weaver.UpstreamClientCanceled {
+		instrumentation.MetricsFromContext(r.Context()).IncrementAPIBackendUpstreamErrorCount(eh.ACLName, eh.BackendName, string(eh.Failure))
+	}
+
+	if weaver.IsGRPCRequest(r) {
+		weaver.WriteGRPCError(w, failure.grpcStatus, failure.code)
+		return
+	}
+
+	writeError(w, r, eh.Templates, eh.ACLName, failure.httpStatus, errorDetails{
+		Code:            failure.code,
+		Message:         failure.message,
+		MessageTitle:    "Failure",
+		MessageSeverity: "failure",
+	})
+}
+
+// errPluginHandler answers a request a plugin stopped, with the plugin's error or a 500
+type errPluginHandler struct {
+	ACLName   string
+	Templates weaver.ErrorTemplates
+	Err       error
+}
+
+func (eh errPluginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rejection, ok := errors.Cause(eh.Err).(*weaver.PluginError)
+	if ok {
+		logger.Inforf(r, "plugin rejected request for acl %s: %s", eh.ACLName, rejection)
+	} else {
+		logger.Errorrf(r, "plugin failed for acl %s: %s", eh.ACLName, eh.Err)
+
+		rejection = &weaver.PluginError{
+			Status:     http.StatusInternalServerError,
+			GRPCStatus: weaver.GRPCStatusInternal,
+			Code:       "weaver:plugin:error",
+			Message:    "Something went wrong",
+		}
+	}
+
+	if eh.ACLName != "" {
+		instrumentation.MetricsFromContext(r.Context()).IncrementInternalAPIStatusCount(eh.ACLName, rejection.Status)
+	}
+
+	for name, values := range rejection.Header {
+		w.Header()[name] = values
+	}
+
+	if weaver.IsGRPCRequest(r) {
+		grpcStatus := rejection.GRPCStatus
+		if grpcStatus == 0 {
+			grpcStatus = weaver.GRPCStatusInternal
+		}
+
+		weaver.WriteGRPCError(w, grpcStatus, rejection.Code)
+		return
+	}
+
+	writeError(w, r, eh.Templates, eh.ACLName, rejection.Status, errorDetails{
+		Code:            rejection.Code,
+		Message:         rejection.Message,
+		MessageTitle:    "Failure",
+		MessageSeverity: "failure",
+	})
 }
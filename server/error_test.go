@@ -5,6 +5,9 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gojektech/weaver"
+	"github.com/gojektech/weaver/pkg/instrumentation"
+	"github.com/gojektech/weaver/pkg/requestid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,3 +40,86 @@ func Test503Handler(t *testing.T) {
 	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
 	assert.Equal(t, "{\"errors\":[{\"code\":\"weaver:service:unavailable\",\"message\":\"Something went wrong\",\"message_title\":\"Failure\",\"message_severity\":\"failure\"}]}", w.Body.String())
 }
+
+func Test413Handler(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/hello", nil)
+
+	err413Handler{}.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, "{\"errors\":[{\"code\":\"weaver:request:too_large\",\"message\":\"Request body too large\",\"message_title\":\"Failure\",\"message_severity\":\"failure\"}]}", w.Body.String())
+}
+
+func TestErrorHandlerRendersACLErrorTemplate(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/hello", nil)
+	r = r.WithContext(requestid.NewContext(r.Context(), "req-1"))
+
+	templates := weaver.ErrorTemplates{
+		"weaver:service:unavailable": {
+			Status:  502,
+			Headers: map[string]string{"Retry-After": "5"},
+			JSON:    `{"error":{"code":{{json .Code}},"api":{{json .ACLID}},"request_id":{{json .RequestID}}}}`,
+		},
+	}
+
+	err503Handler{ACLName: "svc-01", Templates: templates}.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"error":{"code":"weaver:service:unavailable","api":"svc-01","request_id":"req-1"}}`, w.Body.String())
+}
+
+func TestErrorHandlerFallsBackToGlobalErrorTemplate(t *testing.T) {
+	templates := weaver.ErrorTemplates{
+		weaver.ErrorDefaultTemplate: {Text: "{{.Code}}: {{.Message}}"},
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/hello", nil)
+	r = r.WithContext(withErrorTemplates(r.Context(), templates))
+	r.Header.Set("Accept", "text/plain")
+
+	notFoundError(w, r)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "weaver:route:not_found: Something went wrong", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "/hello", nil)
+
+	notFoundError(w, r)
+
+	assert.Equal(t, "{\"errors\":[{\"code\":\"weaver:route:not_found\",\"message\":\"Something went wrong\",\"message_title\":\"Failure\",\"message_severity\":\"failure\"}]}", w.Body.String())
+}
+
+func TestUpstreamHandlerCountsBackendFailures(t *testing.T) {
+	sink := &recordingSink{}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/hello", nil)
+	r = r.WithContext(instrumentation.NewMetricsContext(r.Context(), instrumentation.NewMetrics(sink)))
+
+	errUpstreamHandler{ACLName: "svc-01", BackendName: "foo", Failure: weaver.UpstreamConnectionReset}.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, []string{
+		"request.api.svc-01.internal.status.502.count",
+		"request.api.svc-01.backend.foo.upstream.connection_reset.count",
+	}, sink.recorded())
+}
+
+func TestUpstreamHandlerLeavesClientCancelsOutOfUpstreamCounts(t *testing.T) {
+	sink := &recordingSink{}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/hello", nil)
+	r = r.WithContext(instrumentation.NewMetricsContext(r.Context(), instrumentation.NewMetrics(sink)))
+
+	errUpstreamHandler{ACLName: "svc-01", BackendName: "foo", Failure: weaver.UpstreamClientCanceled}.ServeHTTP(w, r)
+
+	assert.Equal(t, statusClientClosedRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "weaver:request:canceled")
+	assert.Equal(t, []string{"request.api.svc-01.internal.status.499.count"}, sink.recorded())
+}
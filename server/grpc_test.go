@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gojektech/weaver"
+	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// grpcEchoBackend echoes every chunk of the request stream back as soon as it is read, like a
+// bidirectional streaming gRPC service, and ends the call with an OK status in the trailers
+func grpcEchoBackend(name string) *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Header().Set("X-Backend", name)
+		w.Header().Set("X-Method", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		buf := make([]byte, 1024)
+		for {
+			n, err := r.Body.Read(buf)
+			if n > 0 {
+				w.Write(buf[:n])
+				w.(http.Flusher).Flush()
+			}
+
+			if err != nil {
+				break
+			}
+		}
+
+		w.Header().Set("Grpc-Status", "0")
+	}))
+
+	ts.Config.Protocols = serverProtocols(true)
+	ts.Start()
+
+	return ts
+}
+
+func newGRPCProxy(t *testing.T, shardConfig string) *httptest.Server {
+	config.Load()
+	logger.SetupLogger()
+
+	acl := withEndpoint(t, &weaver.ACL{
+		ID:        "drivers-grpc",
+		Criterion: "GRPCService(`gojek.drivers.v1.Drivers`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:     "grpc-metadata",
+			ShardExpr:   "x-driver-id",
+			ShardFunc:   "lookup",
+			ShardConfig: json.RawMessage(shardConfig),
+		},
+	})
+
+	rtr := NewRouter(&mockRouteLoader{})
+	require.NoError(t, rtr.upsertACL(acl), "should not have failed to add the gRPC route")
+
+	ts := httptest.NewUnstartedServer(Recover(&proxy{router: rtr}))
+	ts.Config.Protocols = serverProtocols(true)
+	ts.Start()
+
+	return ts
+}
+
+func grpcCall(t *testing.T, url, driverID string, body io.Reader) *http.Response {
+	req, err := http.NewRequest("POST", url, body)
+	require.NoError(t, err, "should not have failed to create the request")
+
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	req.Header.Set("X-Driver-Id", driverID)
+
+	res, err := h2cClient().Do(req)
+	require.NoError(t, err, "should not have failed to make the gRPC call")
+	require.Equal(t, 2, res.ProtoMajor, "should have made the call over HTTP/2")
+
+	return res
+}
+
+func TestProxyShardsGRPCCallsOnMetadata(t *testing.T) {
+	backendA, backendB := grpcEchoBackend("a"), grpcEchoBackend("b")
+	defer backendA.Close()
+	defer backendB.Close()
+
+	ts := newGRPCProxy(t, fmt.Sprintf(`{
+		"1": { "backend_name": "a", "backend": "%s", "protocol": "h2c" },
+		"2": { "backend_name": "b", "backend": "%s", "protocol": "h2c" }
+	}`, backendA.URL, backendB.URL))
+	defer ts.Close()
+
+	res := grpcCall(t, ts.URL+"/gojek.drivers.v1.Drivers/Locate", "2", strings.NewReader("hello"))
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err, "should not have failed to read the response")
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/grpc", res.Header.Get("Content-Type"))
+	assert.Equal(t, "b", res.Header.Get("X-Backend"))
+	assert.Equal(t, "/gojek.drivers.v1.Drivers/Locate", res.Header.Get("X-Method"))
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+}
+
+func TestProxyStreamsGRPCCallsBothWays(t *testing.T) {
+	backend := grpcEchoBackend("a")
+	defer backend.Close()
+
+	ts := newGRPCProxy(t, fmt.Sprintf(`{ "1": { "backend_name": "a", "backend": "%s", "protocol": "h2c" } }`, backend.URL))
+	defer ts.Close()
+
+	requestStream, requestWriter := io.Pipe()
+
+	res := grpcCall(t, ts.URL+"/gojek.drivers.v1.Drivers/Track", "1", requestStream)
+	defer res.Body.Close()
+
+	for _, message := range []string{"ping-1", "ping-2"} {
+		_, err := requestWriter.Write([]byte(message))
+		require.NoError(t, err, "should not have failed to send a message")
+
+		echoed := make([]byte, len(message))
+		_, err = io.ReadFull(res.Body, echoed)
+		require.NoError(t, err, "should have received the echo before closing the stream")
+
+		assert.Equal(t, message, string(echoed))
+	}
+
+	requestWriter.Close()
+
+	_, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err, "should not have failed to finish the stream")
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+}
+
+func TestProxyAnswersGRPCErrorsWithStatusCodes(t *testing.T) {
+	ts := newGRPCProxy(t, `{ "1": { "backend_name": "a", "backend": "http://127.0.0.1:1", "protocol": "h2c" } }`)
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		path, driverID, status string
+	}{
+		{"/gojek.drivers.v1.Vehicles/Locate", "1", "12"},
+		{"/gojek.drivers.v1.Drivers/Locate", "3", "14"},
+		{"/gojek.drivers.v1.Drivers/Locate", "1", "14"},
+	} {
+		res := grpcCall(t, ts.URL+tc.path, tc.driverID, strings.NewReader("hello"))
+		res.Body.Close()
+
+		assert.Equal(t, http.StatusOK, res.StatusCode, tc.path)
+		assert.Equal(t, "application/grpc", res.Header.Get("Content-Type"), tc.path)
+		assert.Equal(t, tc.status, res.Header.Get("Grpc-Status"), tc.path)
+	}
+}
+
+func TestExpandCriterion(t *testing.T) {
+	criterion, err := expandCriterion("Host(`drivers.gojek.io`) && GRPCMethod(\"gojek.drivers.v1.Drivers\", `Locate`)")
+	require.NoError(t, err, "should not have failed to expand the criterion")
+	assert.Equal(t, "Host(`drivers.gojek.io`) && Path(`/gojek.drivers.v1.Drivers/Locate`) && "+grpcContentType, criterion)
+
+	criterion, err = expandCriterion("GRPCService(`gojek.drivers.v1.Drivers`)")
+	require.NoError(t, err, "should not have failed to expand the criterion")
+	assert.Equal(t, "PathRegexp(`^/gojek\\.drivers\\.v1\\.Drivers/[^/]+$`) && "+grpcContentType, criterion)
+
+	criterion, err = expandCriterion("Method(`GET`) && PathRegexp(`/drivers`)")
+	require.NoError(t, err, "should not have failed to expand the criterion")
+	assert.Equal(t, "Method(`GET`) && PathRegexp(`/drivers`)", criterion)
+
+	_, err = expandCriterion("GRPCService(`gojek/drivers`)")
+	assert.Error(t, err, "should have failed on an invalid service name")
+
+	_, err = expandCriterion("GRPCMethod(`gojek.drivers.v1.Drivers`, `Locate.All`)")
+	assert.Error(t, err, "should have failed on an invalid method name")
+}
@@ -1,93 +1,260 @@
 package server
 
 import (
+	"bufio"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/gojektech/weaver"
 	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/accesslog"
 	"github.com/gojektech/weaver/pkg/instrumentation"
 	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/gojektech/weaver/pkg/matcher"
+	"github.com/gojektech/weaver/pkg/redact"
+	"github.com/gojektech/weaver/pkg/requestid"
 	newrelic "github.com/newrelic/go-agent"
+	"github.com/pkg/errors"
 )
 
 type proxy struct {
 	router *Router
+
+	// accessLog - Where requests are access logged, the logger set up from ACCESS_LOG_* when nil
+	accessLog *accesslog.Logger
+
+	// plugins - Run on every request, before the plugins of the ACL it is routed to
+	plugins weaver.PluginChain
 }
 
 func (proxy *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rw := &wrapperResponseWriter{ResponseWriter: w}
 
-	if r.URL.Path == "/ping" || r.URL.Path == "/" {
-		proxy.pingHandler(rw, r)
-		return
-	}
+	r = assignRequestID(rw, r)
+
+	body := countRequestBody(r)
+	entry := &accesslog.Entry{Time: time.Now(), RequestID: requestid.FromContext(r.Context()), Request: r}
+	defer logAccess(proxy.accessLog, entry, rw, body)
 
+	metrics := instrumentation.MetricsFromContext(r.Context())
 	timing := instrumentation.NewTiming()
 
-	defer instrumentation.TimeTotalLatency(timing)
-	instrumentation.IncrementTotalRequestCount()
+	defer metrics.TimeTotalLatency(timing)
+	metrics.IncrementTotalRequestCount()
+
+	r, err := proxy.plugins.PreRoute(r)
+	if err != nil {
+		errPluginHandler{Err: err}.ServeHTTP(rw, r)
+		return
+	}
 
 	acl, err := proxy.router.Route(r)
 	if err != nil || acl == nil {
-		logger.Errorrf(r, "failed to find route: %+v for request: %s", err, r.URL.String())
+		logger.Errorrf(r, "failed to find route: %+v", err)
 
 		notFoundError(rw, r)
 		return
 	}
 
-	backend, err := acl.Endpoint.Shard(r)
+	entry.APIName = acl.ID
+	entry.Redaction = acl.RedactionPolicy
+	entry.Sampling = acl.AccessLog
+	r = r.WithContext(redact.NewContext(r.Context(), entry.Redaction))
+
+	if acl.Level != nil {
+		r = r.WithContext(logger.NewLevelContext(r.Context(), *acl.Level))
+	}
+
+	plugins := proxy.pluginsFor(acl)
+
+	r, err = plugins.PostRoute(r, acl)
+	if err != nil {
+		errPluginHandler{ACLName: acl.ID, Templates: acl.ErrorTemplates, Err: err}.ServeHTTP(rw, r)
+		return
+	}
+
+	backend, shardKey, err := acl.Endpoint.Shard(r)
+	if errors.Cause(err) == matcher.ErrBodyTooLarge {
+		logger.Errorrf(r, "request body too large for acl %s", acl.ID)
+
+		err413Handler{ACLName: acl.ID, Templates: acl.ErrorTemplates}.ServeHTTP(rw, r)
+		return
+	}
+
 	if backend == nil || err != nil {
-		logger.Errorrf(r, "failed to find backend for acl %s for: %s, error: %s", acl.ID, r.URL.String(), err)
+		logger.Errorrf(r, "failed to find backend for acl %s, error: %s", acl.ID, err)
 
-		err503Handler{ACLName: acl.ID}.ServeHTTP(rw, r)
+		err503Handler{ACLName: acl.ID, Templates: acl.ErrorTemplates}.ServeHTTP(rw, r)
 		return
 	}
 
-	instrumentation.IncrementAPIBackendRequestCount(acl.ID, backend.Name)
+	route := &weaver.RequestRoute{
+		ACL:      acl,
+		Backend:  backend,
+		ShardKey: shardKey,
+		Plugins:  plugins,
+	}
+	r = weaver.WithRequestRoute(r, route)
+
+	entry.Backend, entry.DownstreamHost, entry.ShardKey = backend.Name, backend.Server.String(), shardKey
 
-	instrumentation.IncrementAPIRequestCount(acl.ID)
+	r, err = plugins.PostShard(r, route)
+	if err != nil {
+		errPluginHandler{ACLName: acl.ID, Templates: acl.ErrorTemplates, Err: err}.ServeHTTP(rw, r)
+		return
+	}
+
+	metrics.IncrementAPIBackendRequestCount(acl.ID, backend.Name)
+
+	metrics.IncrementAPIRequestCount(acl.ID)
 	apiTiming := instrumentation.NewTiming()
-	defer instrumentation.TimeAPILatency(acl.ID, apiTiming)
+	defer metrics.TimeAPILatency(acl.ID, apiTiming)
 
 	apiBackendTiming := instrumentation.NewTiming()
-	defer instrumentation.TimeAPIBackendLatency(acl.ID, backend.Name, apiBackendTiming)
+	defer metrics.TimeAPIBackendLatency(acl.ID, backend.Name, apiBackendTiming)
 
 	var s newrelic.ExternalSegment
 	if txn, ok := w.(newrelic.Transaction); ok {
 		s = newrelic.StartExternalSegment(txn, r)
 	}
+	// An upgraded connection is pinned to the backend chosen at handshake, the reverse proxy only
+	// returns once either side closes it
+	rw.onHijack = func() { metrics.IncrementUpgradedConnections(acl.ID) }
 	backend.Handler.ServeHTTP(rw, r)
 
+	if rw.hijacked {
+		metrics.DecrementUpgradedConnections(acl.ID)
+	}
+
+	if route.PluginErr != nil {
+		errPluginHandler{ACLName: acl.ID, Templates: acl.ErrorTemplates, Err: route.PluginErr}.ServeHTTP(rw, r)
+	}
+
+	if route.UpstreamErr != nil {
+		failure := weaver.ClassifyUpstreamError(route.UpstreamErr)
+		if failure == weaver.UpstreamClientCanceled {
+			logger.Inforf(r, "client canceled request to backend %s for acl %s: %s", backend.Name, acl.ID, route.UpstreamErr)
+		} else {
+			logger.Errorrf(r, "failed to proxy to backend %s for acl %s (%s): %s", backend.Name, acl.ID, failure, route.UpstreamErr)
+		}
+
+		errUpstreamHandler{ACLName: acl.ID, BackendName: backend.Name, Failure: failure, Templates: acl.ErrorTemplates}.ServeHTTP(rw, r)
+	}
+
 	s.End()
 
-	logger.ProxyInfo(acl.ID, backend.Server.String(), r, rw.statusCode, rw)
-	instrumentation.IncrementAPIStatusCount(acl.ID, rw.statusCode)
-	instrumentation.IncrementAPIBackendStatusCount(acl.ID, backend.Name, rw.statusCode)
+	entry.UpstreamStatus = route.UpstreamStatus
+	metrics.IncrementAPIStatusCount(acl.ID, rw.statusCode)
+	metrics.IncrementAPIBackendStatusCount(acl.ID, backend.Name, rw.statusCode)
 }
 
-func (proxy *proxy) pingHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("{}"))
+// pluginsFor returns the proxy's plugins followed by the ACL's
+func (proxy *proxy) pluginsFor(acl *weaver.ACL) weaver.PluginChain {
+	if len(proxy.plugins) == 0 {
+		return acl.Chain
+	}
+
+	plugins := make(weaver.PluginChain, 0, len(proxy.plugins)+len(acl.Chain))
+	return append(append(plugins, proxy.plugins...), acl.Chain...)
 }
 
-func wrapNewRelicHandler(proxy *proxy) http.Handler {
+func logAccess(accessLog *accesslog.Logger, entry *accesslog.Entry, rw *wrapperResponseWriter, body *countingReadCloser) {
+	entry.Latency = time.Since(entry.Time)
+	entry.Status = rw.status()
+	entry.BytesOut = rw.bytesWritten
+
+	if body != nil {
+		entry.BytesIn = body.bytesRead
+	}
+
+	if accessLog != nil {
+		accessLog.Log(entry)
+		return
+	}
+
+	accesslog.Log(entry)
+}
+
+// assignRequestID gives the request an ID, keeping the client's when trusted and in the configured
+// format, which is forwarded to the backend and echoed in the response in the configured header
+func assignRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	cfg := config.RequestID()
+
+	var id string
+	if cfg.TrustIncoming() {
+		id = r.Header.Get(cfg.Header())
+	}
+
+	if !requestid.Valid(cfg.Format(), id) {
+		id = requestid.New(cfg.Format())
+	}
+
+	r = r.WithContext(requestid.NewContext(r.Context(), id))
+	r.Header.Set(cfg.Header(), id)
+	w.Header().Set(cfg.Header(), id)
+
+	return r
+}
+
+func wrapNewRelicHandler(next http.Handler) http.Handler {
 	if !config.NewRelicConfig().Enabled {
-		return proxy
+		return next
 	}
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 
-		if path == "/ping" {
-			proxy.ServeHTTP(w, r)
-			return
-		}
-
-		_, next := newrelic.WrapHandleFunc(instrumentation.NewRelicApp(), path,
+		_, transaction := newrelic.WrapHandleFunc(instrumentation.NewRelicApp(), path,
 			func(w http.ResponseWriter, r *http.Request) {
-				proxy.ServeHTTP(w, r)
+				if txn, ok := w.(newrelic.Transaction); ok && isUpgradeRequest(r) {
+					w = &newRelicUpgradeWriter{ResponseWriter: txn, transaction: txn}
+				}
+
+				next.ServeHTTP(w, r)
 			})
 
-		next(w, r)
+		transaction(w, r)
 	})
 }
+
+// isUpgradeRequest tells whether the request is a protocol upgrade handshake, Connection naming the
+// upgrade token and Upgrade the protocol
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+
+	for _, value := range r.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// newRelicUpgradeWriter ends the New Relic transaction of an upgrade handshake once its connection is
+// hijacked, the transaction would otherwise last as long as the upgraded connection
+type newRelicUpgradeWriter struct {
+	http.ResponseWriter
+	transaction newrelic.Transaction
+}
+
+func (w *newRelicUpgradeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w.transaction.End()
+	return conn, rw, nil
+}
@@ -5,12 +5,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/gojektech/weaver/pkg/shard"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gojektech/weaver"
+	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/accesslog"
 	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/gojektech/weaver/pkg/redact"
+	"github.com/gojektech/weaver/pkg/requestid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -23,6 +31,7 @@ type ProxySuite struct {
 }
 
 func (ps *ProxySuite) SetupTest() {
+	config.Load()
 	logger.SetupLogger()
 
 	routeLoader := &mockRouteLoader{}
@@ -128,6 +137,88 @@ func (ps *ProxySuite) TestProxyHandlerOnBodyBasedMatcherWithModuloSharding() {
 	assert.Equal(ps.T(), "foobar", w.Body.String())
 }
 
+func (ps *ProxySuite) TestProxyHandlerOnBodyBasedMatcherStreamsFullBodyToBackend() {
+	requestBody := `{ "drivers": { "id": "122" }, "padding": "` + strings.Repeat("x", 64*1024) + `" }`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ := ioutil.ReadAll(r.Body)
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(receivedBody)
+	}))
+
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`POST`) && PathRegexp(`/drivers`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:      "body",
+			ShardExpr:    ".drivers.id",
+			ShardFunc:    "modulo",
+			MaxBodyBytes: 1024,
+			ShardConfig: json.RawMessage(fmt.Sprintf(`{
+				"0": {
+					"backend_name": "foo",
+					"backend":      "%s"
+				}
+			}`, server.URL)),
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	_ = ps.rtr.UpsertRoute(acl.Criterion, acl)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/drivers", strings.NewReader(requestBody))
+
+	proxy := proxy{router: ps.rtr}
+	proxy.ServeHTTP(w, r)
+
+	assert.Equal(ps.T(), http.StatusOK, w.Code)
+	assert.Equal(ps.T(), requestBody, w.Body.String())
+}
+
+func (ps *ProxySuite) TestProxyHandlerOnBodyBasedMatcherWhenBodyExceedsMaxBodyBytes() {
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`POST`) && PathRegexp(`/drivers`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:      "body",
+			ShardExpr:    ".drivers.id",
+			ShardFunc:    "modulo",
+			MaxBodyBytes: 16,
+			ShardConfig: json.RawMessage(`{
+				"0": {
+					"backend_name": "foo",
+					"backend":      "http://shard00"
+				}
+			}`),
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	_ = ps.rtr.UpsertRoute(acl.Criterion, acl)
+
+	w := httptest.NewRecorder()
+	body := bytes.NewReader([]byte(`{ "name": "hello world", "drivers": { "id": "122" } }`))
+	r := httptest.NewRequest("POST", "/drivers", body)
+
+	proxy := proxy{router: ps.rtr}
+	proxy.ServeHTTP(w, r)
+
+	assert.Equal(ps.T(), http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(ps.T(), "{\"errors\":[{\"code\":\"weaver:request:too_large\",\"message\":\"Request body too large\",\"message_title\":\"Failure\",\"message_severity\":\"failure\"}],\"request_id\":\""+w.Header().Get("X-Request-Id")+"\"}", w.Body.String())
+}
+
 func (ps *ProxySuite) TestProxyHandlerOnPathBasedMatcherWithModuloSharding() {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -174,6 +265,280 @@ func (ps *ProxySuite) TestProxyHandlerOnPathBasedMatcherWithModuloSharding() {
 	assert.Equal(ps.T(), "foobar", w.Body.String())
 }
 
+func (ps *ProxySuite) TestProxyHandlerAppliesACLHeaderOperations() {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Internal", "secret")
+		w.Header().Set("X-Received-Shard", r.Header.Get("X-Weaver-Shard"))
+		w.Header().Set("X-Received-Key", r.Header.Get("X-Shard-Key"))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`GET`) && PathRegexp(`/drivers`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:   "path",
+			ShardExpr: `/drivers/(\d+)`,
+			ShardFunc: "modulo",
+			ShardConfig: json.RawMessage(fmt.Sprintf(`{
+				"0": {
+					"backend_name": "foo",
+					"backend":      "%s"
+				}
+			}`, server.URL)),
+		},
+		Headers: &weaver.HeaderConfig{
+			Request: &weaver.HeaderOps{
+				Set: map[string]string{
+					"X-Weaver-Shard": "{{backend_name}}",
+					"X-Shard-Key":    "{{shard_key}}",
+				},
+			},
+			Response: &weaver.HeaderOps{
+				Remove: []string{"X-Internal"},
+			},
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	_ = ps.rtr.UpsertRoute(acl.Criterion, acl)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/drivers/123", nil)
+
+	proxy := proxy{router: ps.rtr}
+	proxy.ServeHTTP(w, r)
+
+	assert.Equal(ps.T(), http.StatusOK, w.Code)
+	assert.Equal(ps.T(), "foo", w.Header().Get("X-Received-Shard"))
+	assert.Equal(ps.T(), "123", w.Header().Get("X-Received-Key"))
+	assert.Equal(ps.T(), "", w.Header().Get("X-Internal"))
+}
+
+func (ps *ProxySuite) TestProxyHandlerRewritesPathPerEndpointAndBackend() {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`GET`) && PathRegexp(`/gojek/hello-service/.*`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:   "path",
+			ShardExpr: `/gojek/hello-service/(GF-|R-).*`,
+			ShardFunc: "lookup",
+			Rewrite:   &weaver.RewriteConfig{StripPrefix: "/gojek/hello-service"},
+			ShardConfig: json.RawMessage(fmt.Sprintf(`{
+				"GF-": {
+					"backend_name": "foo",
+					"backend":      "%s/base"
+				},
+				"R-": {
+					"backend_name": "bar",
+					"backend":      "%s",
+					"rewrite":      { "regex": "^/gojek/hello-service/R-(\\d+)$", "replacement": "/v2/rides/$1" }
+				}
+			}`, server.URL, server.URL)),
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	_ = ps.rtr.UpsertRoute(acl.Criterion, acl)
+
+	proxy := proxy{router: ps.rtr}
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/gojek/hello-service/GF-1234", nil))
+
+	assert.Equal(ps.T(), http.StatusOK, w.Code)
+	assert.Equal(ps.T(), "/base/GF-1234", w.Body.String())
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest("GET", "/gojek/hello-service/R-42", nil))
+
+	assert.Equal(ps.T(), http.StatusOK, w.Code)
+	assert.Equal(ps.T(), "/v2/rides/42", w.Body.String())
+}
+
+func (ps *ProxySuite) TestProxyHandlerAssignsRequestID() {
+	var forwardedID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedID = r.Header.Get("X-Request-Id")
+		w.Header().Set("X-Request-Id", forwardedID)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`GET`) && PathRegexp(`/drivers`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:     "path",
+			ShardExpr:   "/(drivers)",
+			ShardFunc:   "none",
+			ShardConfig: json.RawMessage(fmt.Sprintf(`{ "backend_name": "foo", "backend": "%s" }`, server.URL)),
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	_ = ps.rtr.UpsertRoute(acl.Criterion, acl)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/drivers", nil)
+	r.Header.Set("X-Request-Id", "untrusted")
+
+	proxy := proxy{router: ps.rtr}
+	proxy.ServeHTTP(w, r)
+
+	assert.Equal(ps.T(), http.StatusOK, w.Code)
+	assert.Len(ps.T(), w.Header()["X-Request-Id"], 1)
+	assert.Equal(ps.T(), forwardedID, w.Header().Get("X-Request-Id"))
+	assert.Len(ps.T(), forwardedID, 36, "should have replaced the incoming ID with a UUID")
+}
+
+func (ps *ProxySuite) TestProxyHandlerWritesAccessLog() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(append(body, body...))
+	}))
+	defer server.Close()
+
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`POST`) && PathRegexp(`/drivers`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:     "path",
+			ShardExpr:   "/(drivers)",
+			ShardFunc:   "none",
+			ShardConfig: json.RawMessage(fmt.Sprintf(`{ "backend_name": "foo", "backend": "%s" }`, server.URL)),
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	_ = ps.rtr.UpsertRoute(acl.Criterion, acl)
+
+	var out bytes.Buffer
+	accessLogger, err := accesslog.New("json", []string{"status", "upstream_status", "bytes_in", "bytes_out", "api_name", "backend", "shard_key", "request_id"}, &out)
+	require.NoError(ps.T(), err)
+
+	accesslog.SetLogger(accessLogger)
+	defer accesslog.SetLogger(nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/drivers", strings.NewReader("hello"))
+
+	proxy := proxy{router: ps.rtr}
+	proxy.ServeHTTP(w, r)
+
+	assert.Equal(ps.T(), http.StatusCreated, w.Code)
+	assert.JSONEq(ps.T(), fmt.Sprintf(`{"status": 201, "upstream_status": 201, "bytes_in": 5, "bytes_out": 10,
+		"api_name": "svc-01", "backend": "foo", "shard_key": "drivers", "request_id": %q}`, w.Header().Get("X-Request-Id")), out.String())
+}
+
+func (ps *ProxySuite) TestProxyHandlerLogsWithACLRedactionPolicy() {
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`GET`) && PathRegexp(`/drivers`)",
+		Redaction: &redact.Policy{QueryParams: []string{"^phone$"}},
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:     "path",
+			ShardExpr:   "/(drivers)",
+			ShardFunc:   "none",
+			ShardConfig: json.RawMessage(`{ "backend_name": "foo", "backend": "http://127.0.0.1:1" }`),
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	require.NoError(ps.T(), ps.rtr.upsertACL(acl), "should have merged the redaction policy when loading the acl")
+
+	var out bytes.Buffer
+	accessLogger, err := accesslog.New("json", []string{"uri", "request_headers"}, &out)
+	require.NoError(ps.T(), err)
+
+	accesslog.SetLogger(accessLogger)
+	defer accesslog.SetLogger(nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/drivers?phone=0812&city=jkt", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+
+	proxy := proxy{router: ps.rtr}
+	proxy.ServeHTTP(w, r)
+
+	assert.JSONEq(ps.T(), fmt.Sprintf(`{"uri": "/drivers?phone=[REDACTED]&city=jkt",
+		"request_headers": {"authorization": "[REDACTED]", "x_request_id": %q}}`, w.Header().Get("X-Request-Id")), out.String())
+}
+
+func (ps *ProxySuite) TestProxyHandlerKeepsTrustedIncomingRequestID() {
+	os.Setenv("REQUEST_ID_TRUST_INCOMING", "true")
+	config.Load()
+	defer func() {
+		os.Unsetenv("REQUEST_ID_TRUST_INCOMING")
+		config.Load()
+	}()
+
+	id := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/unrouted", nil)
+	r.Header.Set("X-Request-Id", id)
+
+	proxy := proxy{router: ps.rtr}
+	proxy.ServeHTTP(w, r)
+
+	assert.Equal(ps.T(), http.StatusNotFound, w.Code)
+	assert.Equal(ps.T(), id, w.Header().Get("X-Request-Id"))
+	assert.Contains(ps.T(), w.Body.String(), `"request_id":"`+id+`"`)
+}
+
+func (ps *ProxySuite) TestProxyHandlerReplacesTrustedIncomingRequestIDInAnotherFormat() {
+	os.Setenv("REQUEST_ID_TRUST_INCOMING", "true")
+	config.Load()
+	defer func() {
+		os.Unsetenv("REQUEST_ID_TRUST_INCOMING")
+		config.Load()
+	}()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/unrouted", nil)
+	r.Header.Set("X-Request-Id", strings.Repeat("a", 4096))
+
+	proxy := proxy{router: ps.rtr}
+	proxy.ServeHTTP(w, r)
+
+	assert.Equal(ps.T(), http.StatusNotFound, w.Code)
+	assert.True(ps.T(), requestid.Valid(requestid.FormatUUID, w.Header().Get("X-Request-Id")))
+}
+
 func (ps *ProxySuite) TestProxyHandlerOnFailureRouting() {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest("GET", "/GF-1234", nil)
@@ -182,7 +547,7 @@ func (ps *ProxySuite) TestProxyHandlerOnFailureRouting() {
 	proxy.ServeHTTP(w, r)
 
 	assert.Equal(ps.T(), http.StatusNotFound, w.Code)
-	assert.Equal(ps.T(), "{\"errors\":[{\"code\":\"weaver:route:not_found\",\"message\":\"Something went wrong\",\"message_title\":\"Failure\",\"message_severity\":\"failure\"}]}", w.Body.String())
+	assert.Equal(ps.T(), "{\"errors\":[{\"code\":\"weaver:route:not_found\",\"message\":\"Something went wrong\",\"message_title\":\"Failure\",\"message_severity\":\"failure\"}],\"request_id\":\""+w.Header().Get("X-Request-Id")+"\"}", w.Body.String())
 }
 
 func (ps *ProxySuite) TestProxyHandlerOnMissingBackend() {
@@ -221,22 +586,115 @@ func (ps *ProxySuite) TestProxyHandlerOnMissingBackend() {
 	assert.Equal(ps.T(), http.StatusServiceUnavailable, w.Code)
 }
 
-func (ps *ProxySuite) TestHealthCheckWithPingRoute() {
+func (ps *ProxySuite) TestProxyHandlerOnUpstreamTimeout() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`GET`) && PathRegexp(`/drivers`)",
+		Timeouts:  &weaver.TimeoutConfig{RequestInMS: 50},
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:     "path",
+			ShardExpr:   "/(drivers)",
+			ShardFunc:   "none",
+			ShardConfig: json.RawMessage(fmt.Sprintf(`{ "backend_name": "foo", "backend": "%s" }`, server.URL)),
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	_ = ps.rtr.UpsertRoute(acl.Criterion, acl)
+
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest("GET", "/ping", nil)
+	r := httptest.NewRequest("GET", "/drivers", nil)
 
 	proxy := proxy{router: ps.rtr}
 	proxy.ServeHTTP(w, r)
 
-	assert.Equal(ps.T(), http.StatusOK, w.Code)
+	assert.Equal(ps.T(), http.StatusGatewayTimeout, w.Code)
+	assert.Equal(ps.T(), "{\"errors\":[{\"code\":\"weaver:upstream:timeout\",\"message\":\"Upstream did not respond in time\",\"message_title\":\"Failure\",\"message_severity\":\"failure\"}],\"request_id\":\""+w.Header().Get("X-Request-Id")+"\"}", w.Body.String())
+}
+
+func (ps *ProxySuite) TestProxyHandlerOnUnreachableBackend() {
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`GET`) && PathRegexp(`/drivers`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:     "path",
+			ShardExpr:   "/(drivers)",
+			ShardFunc:   "none",
+			ShardConfig: json.RawMessage(`{ "backend_name": "foo", "backend": "http://127.0.0.1:1" }`),
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	_ = ps.rtr.UpsertRoute(acl.Criterion, acl)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/drivers", nil)
+
+	proxy := proxy{router: ps.rtr}
+	proxy.ServeHTTP(w, r)
+
+	assert.Equal(ps.T(), http.StatusServiceUnavailable, w.Code)
+	assert.Equal(ps.T(), "{\"errors\":[{\"code\":\"weaver:upstream:unreachable\",\"message\":\"Upstream refused the connection\",\"message_title\":\"Failure\",\"message_severity\":\"failure\"}],\"request_id\":\""+w.Header().Get("X-Request-Id")+"\"}", w.Body.String())
 }
 
-func (ps *ProxySuite) TestHealthCheckWithDefaultRoute() {
+func (ps *ProxySuite) TestProxyHandlerOnUnresolvableBackend() {
+	acl := &weaver.ACL{
+		ID:        "svc-01",
+		Criterion: "Method(`GET`) && PathRegexp(`/drivers`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:     "path",
+			ShardExpr:   "/(drivers)",
+			ShardFunc:   "none",
+			ShardConfig: json.RawMessage(`{ "backend_name": "foo", "backend": "http://backend.invalid" }`),
+		},
+	}
+
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(ps.T(), err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(ps.T(), err, "should not have failed to set endpoint")
+
+	_ = ps.rtr.UpsertRoute(acl.Criterion, acl)
+
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest("GET", "/", nil)
+	r := httptest.NewRequest("GET", "/drivers", nil)
 
 	proxy := proxy{router: ps.rtr}
 	proxy.ServeHTTP(w, r)
 
-	assert.Equal(ps.T(), http.StatusOK, w.Code)
+	assert.Equal(ps.T(), http.StatusBadGateway, w.Code)
+	assert.Contains(ps.T(), w.Body.String(), "weaver:upstream:dns_failure")
+}
+
+func (ps *ProxySuite) TestPingAndRootPathsAreRoutedToACLs() {
+	for _, path := range []string{"/ping", "/"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+
+		proxy := proxy{router: ps.rtr}
+		proxy.ServeHTTP(w, r)
+
+		assert.Equal(ps.T(), http.StatusNotFound, w.Code, "should have routed %s like any other path", path)
+	}
 }
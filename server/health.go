@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// health reports liveness and readiness on the admin server. Weaver is ready once its routes are
+// bootstrapped, the route watcher runs and at least minACLs ACLs are loaded, until it shuts down.
+type health struct {
+	router  *Router
+	minACLs int
+
+	draining int32
+}
+
+type healthStatus struct {
+	Status  string   `json:"status"`
+	ACLs    int      `json:"acls,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+func (h *health) drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+// notReadyReasons lists why weaver should not receive traffic, none when it is ready
+func (h *health) notReadyReasons() []string {
+	var reasons []string
+
+	if atomic.LoadInt32(&h.draining) == 1 {
+		reasons = append(reasons, "shutting down")
+	}
+
+	if !h.router.Bootstrapped() {
+		reasons = append(reasons, "routes are not bootstrapped")
+	}
+
+	if !h.router.Watching() {
+		reasons = append(reasons, "route watcher is not running")
+	}
+
+	if count := h.router.ACLCount(); count < h.minACLs {
+		reasons = append(reasons, fmt.Sprintf("%d ACLs loaded, %d needed", count, h.minACLs))
+	}
+
+	return reasons
+}
+
+func (h *health) liveHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthStatus(w, http.StatusOK, healthStatus{Status: "live"})
+}
+
+func (h *health) readyHandler(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Status: "ready", ACLs: h.router.ACLCount(), Reasons: h.notReadyReasons()}
+	if len(status.Reasons) > 0 {
+		status.Status = "not_ready"
+		writeHealthStatus(w, http.StatusServiceUnavailable, status)
+		return
+	}
+
+	writeHealthStatus(w, http.StatusOK, status)
+}
+
+func writeHealthStatus(w http.ResponseWriter, code int, status healthStatus) {
+	body, _ := json.Marshal(status)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(body)
+}
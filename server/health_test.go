@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gojektech/weaver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func checkHealth(h *health, path string) (int, healthStatus) {
+	w := httptest.NewRecorder()
+	newAdminHandler(nil, h).ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+	var status healthStatus
+	json.Unmarshal(w.Body.Bytes(), &status)
+
+	return w.Code, status
+}
+
+func TestLivenessIsAlwaysOK(t *testing.T) {
+	h := &health{router: NewRouter(&mockRouteLoader{})}
+
+	code, status := checkHealth(h, "/health/live")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "live", status.Status)
+}
+
+func TestReadinessFailsUntilRoutesAreBootstrappedAndWatched(t *testing.T) {
+	loader := &mockRouteLoader{}
+	router := NewRouter(loader)
+	h := &health{router: router, minACLs: 1}
+
+	code, status := checkHealth(h, "/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", status.Status)
+	assert.Equal(t, []string{
+		"routes are not bootstrapped",
+		"route watcher is not running",
+		"0 ACLs loaded, 1 needed",
+	}, status.Reasons)
+
+	loader.On("BootstrapRoutes", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		upsert := args.Get(1).(UpsertRouteFunc)
+		require.NoError(t, upsert(&weaver.ACL{ID: "svc-01", Criterion: "Method(`GET`)"}))
+	})
+	loader.On("WatchRoutes", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	})
+
+	require.NoError(t, router.BootstrapRoutes(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watchDone := make(chan struct{})
+	go func() {
+		router.WatchRouteUpdates(ctx)
+		close(watchDone)
+	}()
+
+	for i := 0; i < 100 && !router.Watching(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	code, status = checkHealth(h, "/health/ready")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", status.Status)
+	assert.Equal(t, 1, status.ACLs)
+
+	cancel()
+	<-watchDone
+
+	code, status = checkHealth(h, "/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"route watcher is not running"}, status.Reasons)
+}
+
+func TestReadinessFailsWhenBootstrapFails(t *testing.T) {
+	loader := &mockRouteLoader{}
+	loader.On("BootstrapRoutes", mock.Anything, mock.Anything).Return(assert.AnError)
+
+	router := NewRouter(loader)
+	assert.Error(t, router.BootstrapRoutes(context.Background()))
+
+	code, status := checkHealth(&health{router: router}, "/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, status.Reasons, "routes are not bootstrapped")
+}
+
+// flakyRouteLoader fails to bootstrap until told to recover, and its watcher stops on its own
+type flakyRouteLoader struct {
+	staticRouteLoader
+
+	mu         sync.Mutex
+	failures   int
+	bootstraps int
+	watchStops chan struct{}
+}
+
+func (frl *flakyRouteLoader) BootstrapRoutes(ctx context.Context, upsert UpsertRouteFunc) error {
+	frl.mu.Lock()
+	frl.bootstraps++
+	failing := frl.bootstraps <= frl.failures
+	frl.mu.Unlock()
+
+	if failing {
+		return errors.New("etcd is unreachable")
+	}
+
+	return frl.staticRouteLoader.BootstrapRoutes(ctx, upsert)
+}
+
+func (frl *flakyRouteLoader) WatchRoutes(ctx context.Context, upsert UpsertRouteFunc, del DeleteRouteFunc) {
+	select {
+	case <-ctx.Done():
+	case <-frl.watchStops:
+	}
+}
+
+func waitUntilReady(h *health) int {
+	code := 0
+	for i := 0; i < 200; i++ {
+		if code, _ = checkHealth(h, "/health/ready"); code == http.StatusOK {
+			break
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return code
+}
+
+func TestReadinessRecoversWhenTheRouteLoaderDoes(t *testing.T) {
+	defer func(min, max time.Duration) { routesMinBackoff, routesMaxBackoff = min, max }(routesMinBackoff, routesMaxBackoff)
+	routesMinBackoff, routesMaxBackoff = time.Millisecond, 10*time.Millisecond
+
+	loader := &flakyRouteLoader{
+		staticRouteLoader: staticRouteLoader{acls: []*weaver.ACL{staticACL(t, "orders", "/orders", "http://localhost:1")}},
+		failures:          3,
+		watchStops:        make(chan struct{}),
+	}
+
+	w, err := New(WithRouteLoader(loader), WithReadinessMinACLs(1))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.Error(t, w.LoadRoutes(ctx))
+	assert.Equal(t, http.StatusOK, waitUntilReady(w.health), "should have retried loading routes")
+
+	loader.watchStops <- struct{}{}
+
+	bootstraps := 0
+	for i := 0; i < 200 && bootstraps < 5; i++ {
+		time.Sleep(5 * time.Millisecond)
+
+		loader.mu.Lock()
+		bootstraps = loader.bootstraps
+		loader.mu.Unlock()
+	}
+
+	assert.Equal(t, 5, bootstraps, "should have reloaded routes before watching again")
+	assert.Equal(t, http.StatusOK, waitUntilReady(w.health), "should have restarted the route watcher")
+}
+
+func TestBootstrapRemovesACLsTheLoaderNoLongerHas(t *testing.T) {
+	loader := &staticRouteLoader{acls: []*weaver.ACL{
+		staticACL(t, "orders", "/orders", "http://localhost:1"),
+		staticACL(t, "users", "/users", "http://localhost:1"),
+	}}
+
+	router := NewRouter(loader)
+	require.NoError(t, router.BootstrapRoutes(context.Background()))
+	assert.Equal(t, 2, router.ACLCount())
+
+	loader.acls = loader.acls[:1]
+	require.NoError(t, router.BootstrapRoutes(context.Background()))
+	assert.Equal(t, 1, router.ACLCount())
+
+	_, err := router.Route(httptest.NewRequest("GET", "/users/1", nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gojektech/weaver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type phasePlugin struct {
+	preRoute  func(req *http.Request) (*http.Request, error)
+	postShard func(req *http.Request, route *weaver.RequestRoute) (*http.Request, error)
+	response  func(res *http.Response, route *weaver.RequestRoute) error
+}
+
+func (pp phasePlugin) Name() string {
+	return "phase"
+}
+
+func (pp phasePlugin) PreRoute(req *http.Request) (*http.Request, error) {
+	if pp.preRoute == nil {
+		return req, nil
+	}
+
+	return pp.preRoute(req)
+}
+
+func (pp phasePlugin) PostShard(req *http.Request, route *weaver.RequestRoute) (*http.Request, error) {
+	if pp.postShard == nil {
+		return req, nil
+	}
+
+	return pp.postShard(req, route)
+}
+
+func (pp phasePlugin) Response(res *http.Response, route *weaver.RequestRoute) error {
+	if pp.response == nil {
+		return nil
+	}
+
+	return pp.response(res, route)
+}
+
+func newPluginWeaver(t *testing.T, backendURL string, aclPlugins []weaver.PluginConfig, plugins ...weaver.Plugin) *Weaver {
+	acl := staticACL(t, "orders", "/orders", backendURL)
+	acl.Plugins = aclPlugins
+
+	w, err := New(WithRouteLoader(&staticRouteLoader{acls: []*weaver.ACL{acl}}), WithPlugins(plugins...))
+	require.NoError(t, err)
+
+	require.NoError(t, w.router.BootstrapRoutes(context.Background()))
+	return w
+}
+
+func pluginErrorResponse(t *testing.T, w *httptest.ResponseRecorder) weaverResponse {
+	var res weaverResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+
+	return res
+}
+
+func TestACLPluginsShortCircuitWithWeaverErrors(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Api-Key")))
+	}))
+	defer backend.Close()
+
+	w := newPluginWeaver(t, backend.URL, []weaver.PluginConfig{
+		{Name: "require-headers", Config: json.RawMessage(`{"headers": ["X-Api-Key"]}`)},
+	})
+
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/orders/1", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "weaver:request:missing_header", pluginErrorResponse(t, rec).Errors[0].Code)
+	assert.Equal(t, "X-Api-Key header is required", pluginErrorResponse(t, rec).Errors[0].Message)
+
+	req := httptest.NewRequest("GET", "/orders/1", nil)
+	req.Header.Set("X-Api-Key", "secret")
+
+	rec = httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "secret", rec.Body.String())
+}
+
+func TestServerPluginsRunBeforeRouting(t *testing.T) {
+	w := newPluginWeaver(t, "http://localhost:1", nil, phasePlugin{
+		preRoute: func(req *http.Request) (*http.Request, error) {
+			return req, &weaver.PluginError{
+				Status:  http.StatusTooManyRequests,
+				Code:    "weaver:request:limited",
+				Message: "Slow down",
+				Header:  http.Header{"Retry-After": {"5"}},
+			}
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/unrouted", nil))
+
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+	assert.Equal(t, "weaver:request:limited", pluginErrorResponse(t, rec).Errors[0].Code)
+}
+
+func TestPluginsSeeTheRouteAndTheResponse(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Internal", "leak")
+		w.Write([]byte(r.Header.Get("X-Backend")))
+	}))
+	defer backend.Close()
+
+	w := newPluginWeaver(t, backend.URL, nil, phasePlugin{
+		postShard: func(req *http.Request, route *weaver.RequestRoute) (*http.Request, error) {
+			req.Header.Set("X-Backend", route.Backend.Name)
+			return req, nil
+		},
+		response: func(res *http.Response, route *weaver.RequestRoute) error {
+			res.Header.Del("X-Internal")
+			return nil
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/orders/1", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "orders-backend", rec.Body.String())
+	assert.Empty(t, rec.Header().Get("X-Internal"))
+}
+
+func TestResponsePluginsCanRejectTheBackendsResponse(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer backend.Close()
+
+	w := newPluginWeaver(t, backend.URL, nil, phasePlugin{
+		response: func(res *http.Response, route *weaver.RequestRoute) error {
+			return &weaver.PluginError{Status: http.StatusForbidden, Code: "weaver:response:forbidden", Message: "Not for you"}
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/orders/1", nil))
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "weaver:response:forbidden", pluginErrorResponse(t, rec).Errors[0].Code)
+}
+
+func TestFailingPluginsAnswerInternalServerError(t *testing.T) {
+	w := newPluginWeaver(t, "http://localhost:1", nil, phasePlugin{
+		postShard: func(req *http.Request, route *weaver.RequestRoute) (*http.Request, error) {
+			return nil, errors.New("rate limiter unreachable")
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/orders/1", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "weaver:plugin:error", pluginErrorResponse(t, rec).Errors[0].Code)
+}
+
+func TestACLsWithPluginsThatCannotBeBuiltAreRejected(t *testing.T) {
+	acl := staticACL(t, "orders", "/orders", "http://localhost:1")
+	acl.Plugins = []weaver.PluginConfig{{Name: "jwt", Config: json.RawMessage(`{}`)}}
+
+	w, err := New(WithRouteLoader(&staticRouteLoader{acls: []*weaver.ACL{acl}}))
+	require.NoError(t, err)
+
+	err = w.router.BootstrapRoutes(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create plugins")
+	assert.Equal(t, 0, w.router.ACLCount())
+
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/orders/1", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "should not have served the acl without its plugins")
+}
@@ -7,25 +7,30 @@ import (
 	raven "github.com/getsentry/raven-go"
 	"github.com/gojektech/weaver/pkg/instrumentation"
 	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/gojektech/weaver/pkg/redact"
 )
 
 func Recover(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
 		defer func() {
 			if err := recover(); err != nil {
-				instrumentation.IncrementCrashCount()
+				// the reverse proxy aborts a response it can no longer complete, e.g. when the
+				// client goes away mid-stream; the server must see it to drop the connection
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
+				instrumentation.MetricsFromContext(r.Context()).IncrementCrashCount()
 
 				var recoveredErr error
 				switch val := err.(type) {
 				case error:
 					recoveredErr = val
 				case string:
-					recoveredErr = fmt.Errorf(val)
+					recoveredErr = fmt.Errorf("%s", val)
 				}
 
-				raven.CaptureError(recoveredErr, map[string]string{"error": recoveredErr.Error(), "request_url": r.URL.String()})
+				raven.CaptureError(recoveredErr, map[string]string{"error": recoveredErr.Error(), "request_url": redact.FromContext(r.Context()).URL(r.URL.String())})
 
 				logger.Errorrf(r, "failed to route request: %+v", err)
 				internalServerError(w, r)
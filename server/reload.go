@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/instrumentation"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+var reloadMu sync.Mutex
+
+// ReloadConfig - Re-reads weaver.conf and applies the settings that can change without a restart to
+// the logger, the statsd client and the backend transports. Changed settings that need a restart
+// are logged and otherwise ignored.
+func ReloadConfig(ctx context.Context) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	applied, restartRequired, err := config.Reload()
+	if err != nil {
+		return errors.Wrap(err, "failed to reload config")
+	}
+
+	for _, key := range restartRequired {
+		log.Printf("ReloadConfig: %s changed, restart weaver to apply it", key)
+	}
+
+	if len(applied) == 0 {
+		log.Printf("ReloadConfig: no changes to apply")
+		return nil
+	}
+
+	var rebootstrap bool
+	for _, key := range applied {
+		switch {
+		case key == "LOGGER_LEVEL":
+			if err := logger.SetLevel(config.LogLevel()); err != nil {
+				log.Printf("ReloadConfig: invalid LOGGER_LEVEL: %s", err)
+			}
+		case key == "STATSD":
+			if err := instrumentation.ReloadStatsDMetrics(); err != nil {
+				log.Printf("ReloadConfig: failed to reload statsd client: %s", err)
+			}
+		case strings.HasPrefix(key, "PROXY_") || key == "UPSTREAM_TLS_PROFILES":
+			rebootstrap = true
+		}
+	}
+
+	// backends are created with the transport settings, re-creating them applies the new ones
+	if server := currentServer(); rebootstrap && server != nil && server.router != nil {
+		if err := server.router.BootstrapRoutes(ctx); err != nil {
+			return errors.Wrap(err, "failed to re-create backends")
+		}
+	}
+
+	log.Printf("ReloadConfig: applied %s", strings.Join(applied, ", "))
+	return nil
+}
+
+// watchConfigFile reloads the configuration whenever the modification time of the config file changes
+func watchConfigFile(ctx context.Context, path string, interval time.Duration) {
+	modTime := configModTime(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			latest := configModTime(path)
+			if latest.Equal(modTime) {
+				continue
+			}
+
+			modTime = latest
+			if err := ReloadConfig(ctx); err != nil {
+				log.Printf("ReloadConfig: %s", err)
+			}
+		}
+	}
+}
+
+func configModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
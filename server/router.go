@@ -3,16 +3,28 @@ package server
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gojektech/weaver"
+	"github.com/gojektech/weaver/pkg/plugin"
+	"github.com/gojektech/weaver/pkg/redact"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/vulcand/route"
 )
 
 type Router struct {
 	route.Router
 	loader RouteLoader
+
+	mu   sync.RWMutex
+	acls map[string]*weaver.ACL
+
+	bootstrapped int32
+	watching     int32
 }
 
 type apiName string
@@ -39,21 +51,163 @@ func NewRouter(loader RouteLoader) *Router {
 	return &Router{
 		Router: route.New(),
 		loader: loader,
+		acls:   map[string]*weaver.ACL{},
 	}
 }
 
 func (router *Router) WatchRouteUpdates(routeSyncCtx context.Context) {
+	atomic.StoreInt32(&router.watching, 1)
+	defer atomic.StoreInt32(&router.watching, 0)
+
 	router.loader.WatchRoutes(routeSyncCtx, router.upsertACL, router.deleteACL)
 }
 
+// BootstrapRoutes - Loads every route of the loader, removing the ACLs loaded before that it no
+// longer has
 func (router *Router) BootstrapRoutes(ctx context.Context) error {
-	return router.loader.BootstrapRoutes(ctx, router.upsertACL)
+	loaded := map[string]bool{}
+	upsert := func(acl *weaver.ACL) error {
+		// an ACL failing to load keeps its previous version, as with a failed update
+		loaded[acl.ID] = true
+		return router.upsertACL(acl)
+	}
+
+	if err := router.loader.BootstrapRoutes(ctx, upsert); err != nil {
+		return err
+	}
+
+	router.removeACLsNotIn(loaded)
+
+	atomic.StoreInt32(&router.bootstrapped, 1)
+	return nil
+}
+
+func (router *Router) removeACLsNotIn(loaded map[string]bool) {
+	var stale []*weaver.ACL
+
+	router.mu.RLock()
+	for id, acl := range router.acls {
+		if !loaded[id] {
+			stale = append(stale, acl)
+		}
+	}
+	router.mu.RUnlock()
+
+	for _, acl := range stale {
+		if err := router.deleteACL(acl); err != nil {
+			log.Printf("Router: failed to remove acl %s no longer loaded: %s", acl.ID, err)
+		}
+	}
+}
+
+// Bootstrapped - Whether routes were loaded successfully at least once
+func (router *Router) Bootstrapped() bool {
+	return atomic.LoadInt32(&router.bootstrapped) == 1
+}
+
+// Watching - Whether route updates are being watched
+func (router *Router) Watching() bool {
+	return atomic.LoadInt32(&router.watching) == 1
+}
+
+// ACLCount - The number of ACLs loaded from the route loader
+func (router *Router) ACLCount() int {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+
+	return len(router.acls)
 }
 
 func (router *Router) upsertACL(acl *weaver.ACL) error {
-	return router.UpsertRoute(acl.Criterion, acl)
+	criterion, err := expandCriterion(acl.Criterion)
+	if err != nil {
+		return errors.Wrapf(err, "failed to expand criterion for acl: %s", acl.ID)
+	}
+
+	if err := prepareACL(acl); err != nil {
+		return errors.Wrapf(err, "failed to prepare acl: %s", acl.ID)
+	}
+
+	if err := router.UpsertRoute(criterion, acl); err != nil {
+		return err
+	}
+
+	router.mu.Lock()
+	previous := router.acls[acl.ID]
+	router.acls[acl.ID] = acl
+	router.mu.Unlock()
+
+	if previous != nil && previous != acl {
+		closeIdleConnections(previous)
+	}
+
+	return nil
 }
 
 func (router *Router) deleteACL(acl *weaver.ACL) error {
-	return router.RemoveRoute(acl.Criterion)
+	criterion, err := expandCriterion(acl.Criterion)
+	if err != nil {
+		return errors.Wrapf(err, "failed to expand criterion for acl: %s", acl.ID)
+	}
+
+	if err := router.RemoveRoute(criterion); err != nil {
+		return err
+	}
+
+	router.mu.Lock()
+	previous := router.acls[acl.ID]
+	delete(router.acls, acl.ID)
+	router.mu.Unlock()
+
+	if previous != nil {
+		closeIdleConnections(previous)
+	}
+
+	return nil
+}
+
+// closeIdleConnections closes the idle connections of an ACL's backends once it is replaced or
+// removed, requests still in flight keep theirs
+func closeIdleConnections(acl *weaver.ACL) {
+	if acl.Endpoint != nil {
+		acl.Endpoint.CloseIdleConnections()
+	}
+}
+
+// prepareACL validates an ACL and builds its plugins, whichever route loader it comes from, so an
+// ACL whose policies cannot be enforced is rejected instead of served without them
+func prepareACL(acl *weaver.ACL) error {
+	if err := acl.Headers.Validate(); err != nil {
+		return errors.Wrap(err, "failed to validate headers")
+	}
+
+	if err := acl.ErrorTemplates.Validate(); err != nil {
+		return errors.Wrap(err, "failed to validate error templates")
+	}
+
+	if err := acl.Redaction.Compile(); err != nil {
+		return errors.Wrap(err, "failed to validate redaction")
+	}
+
+	acl.RedactionPolicy = redact.Default().Override(acl.Redaction)
+
+	if acl.LogLevel != "" {
+		level, err := logrus.ParseLevel(acl.LogLevel)
+		if err != nil {
+			return errors.Wrap(err, "failed to validate log level")
+		}
+
+		acl.Level = &level
+	}
+
+	if len(acl.Plugins) > 0 {
+		chain, err := plugin.NewChain(acl.Plugins)
+		if err != nil {
+			return errors.Wrap(err, "failed to create plugins")
+		}
+
+		acl.Chain = chain
+	}
+
+	return nil
 }
@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gojektech/weaver"
 	"github.com/gojektech/weaver/pkg/shard"
+	"net"
+	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/gojektech/weaver/config"
 	"github.com/gojektech/weaver/pkg/logger"
@@ -136,3 +141,63 @@ func (rs *RouterSuite) TestWatchRouteUpdatesCallsWatchRoutesOfLoader() {
 
 	routeLoader.AssertExpectations(rs.T())
 }
+
+func (rs *RouterSuite) TestUpsertACLClosesIdleConnectionsOfTheReplacedACL() {
+	os.Setenv("PROXY_KEEP_ALIVE_ENABLED", "true")
+	os.Setenv("PROXY_IDLE_CONN_TIMEOUT_IN_MS", "60000")
+	config.Load()
+	defer func() {
+		os.Unsetenv("PROXY_KEEP_ALIVE_ENABLED")
+		os.Unsetenv("PROXY_IDLE_CONN_TIMEOUT_IN_MS")
+		config.Load()
+	}()
+
+	closed := make(chan struct{}, 1)
+	backendServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backendServer.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	backendServer.Start()
+	defer backendServer.Close()
+
+	newACL := func() *weaver.ACL {
+		acl := &weaver.ACL{
+			ID:        "svc-01",
+			Criterion: "PathRegexp(`/svc`)",
+			EndpointConfig: &weaver.EndpointConfig{
+				Matcher:     "path",
+				ShardExpr:   "/(svc)",
+				ShardFunc:   "none",
+				ShardConfig: json.RawMessage(fmt.Sprintf(`{"backend_name": "svc", "backend": "%s"}`, backendServer.URL)),
+			},
+		}
+
+		sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+		require.NoError(rs.T(), err)
+
+		acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+		require.NoError(rs.T(), err)
+
+		return acl
+	}
+
+	previous := newACL()
+	require.NoError(rs.T(), rs.rtr.upsertACL(previous))
+
+	backend, _, err := previous.Endpoint.Shard(httptest.NewRequest("GET", "/svc", nil))
+	require.NoError(rs.T(), err)
+
+	rec := httptest.NewRecorder()
+	backend.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/svc", nil))
+	require.Equal(rs.T(), http.StatusOK, rec.Code)
+
+	require.NoError(rs.T(), rs.rtr.upsertACL(newACL()))
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		rs.T().Fatal("idle connection of the replaced acl was not closed")
+	}
+}
@@ -4,54 +4,121 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"sync"
 
+	"github.com/gojektech/weaver"
 	"github.com/gojektech/weaver/config"
 	"github.com/gojektech/weaver/pkg/util"
 )
 
-var server *Weaver
+// server is the weaver started from the configuration by StartServer, read by ShutdownServer and
+// ReloadConfig from other goroutines
+var (
+	server   *Weaver
+	serverMu sync.RWMutex
+)
+
+func currentServer() *Weaver {
+	serverMu.RLock()
+	defer serverMu.RUnlock()
+
+	return server
+}
+
+func setServer(w *Weaver) {
+	serverMu.Lock()
+	defer serverMu.Unlock()
 
-type Weaver struct {
-	httpServer *http.Server
+	server = w
 }
 
-func ShutdownServer(ctx context.Context) {
-	server.httpServer.Shutdown(ctx)
+// ShutdownServer - Stops weaver gracefully within SHUTDOWN_DRAIN_PERIOD_IN_MS and SHUTDOWN_TIMEOUT_IN_MS
+func ShutdownServer(ctx context.Context) error {
+	server := currentServer()
+	if server == nil {
+		return nil
+	}
+
+	return server.shutdown(ctx, config.ShutdownDrainPeriod(), config.ShutdownTimeout())
+}
+
+// WaitServer - Waits for the route, certificate and config watchers of the weaver started by
+// StartServer to stop once the context it was started with is done
+func WaitServer() {
+	if server := currentServer(); server != nil {
+		server.Wait()
+	}
 }
 
+// StartServer - Starts a weaver configured from weaver.conf and the environment
 func StartServer(ctx context.Context, routeLoader RouteLoader) {
-	proxyRouter := NewRouter(routeLoader)
-	err := proxyRouter.BootstrapRoutes(context.Background())
+	errorTemplates, err := weaver.ParseErrorTemplates(config.ErrorTemplates())
 	if err != nil {
-		log.Printf("StartServer: failed to initialise proxy router: %s", err)
+		log.Fatalf("StartServer: invalid ERROR_TEMPLATES: %s", err)
 	}
 
-	log.Printf("StartServer: bootstraped routes from etcd")
+	keepAliveEnabled := config.Proxy().KeepAliveEnabled()
+	http2Enabled := config.Proxy().HTTP2Enabled()
+	tlsConfig := config.TLS()
 
-	go proxyRouter.WatchRouteUpdates(ctx)
+	var certs *certificateStore
+	if tlsConfig.Enabled() {
+		certs, err = newCertificateStore(tlsConfig.Certificates())
+		if err != nil {
+			log.Fatalf("StartServer: failed to load TLS certificates: %s", err)
+		}
+	}
 
-	proxy := Recover(wrapNewRelicHandler(&proxy{
-		router: proxyRouter,
-	}))
+	w, err := New(
+		WithRouteLoader(routeLoader),
+		WithAddress(config.ProxyServerAddress()),
+		WithAdminAddress(config.AdminServerAddress()),
+		WithErrorTemplates(errorTemplates),
+		WithMiddlewares(wrapNewRelicHandler),
+		WithReadinessMinACLs(config.ReadinessMinACLs()),
+		WithHTTPServer(func(httpServer *http.Server) {
+			httpServer.ReadTimeout = config.ServerReadTimeoutInMillis()
+			httpServer.WriteTimeout = config.ServerWriteTimeoutInMillis()
+			httpServer.SetKeepAlivesEnabled(keepAliveEnabled)
+			httpServer.Protocols = serverProtocols(http2Enabled)
 
-	httpServer := &http.Server{
-		Addr:         config.ProxyServerAddress(),
-		Handler:      proxy,
-		ReadTimeout:  config.ServerReadTimeoutInMillis(),
-		WriteTimeout: config.ServerWriteTimeoutInMillis(),
+			if certs != nil {
+				httpServer.TLSConfig = newTLSConfig(tlsConfig, certs)
+			}
+		}),
+	)
+	if err != nil {
+		log.Fatalf("StartServer: %s", err)
 	}
 
-	keepAliveEnabled := config.Proxy().KeepAliveEnabled()
-	httpServer.SetKeepAlivesEnabled(keepAliveEnabled)
+	w.adminServer.Handler = newAdminHandler(certs, w.health)
+	setServer(w)
 
-	server = &Weaver{
-		httpServer: httpServer,
+	if certs != nil {
+		w.watch(ctx, func() { certs.watch(ctx, tlsConfig.ReloadIntervalInMS()) })
 	}
 
-	log.Printf("StartServer: starting weaver on %s", server.httpServer.Addr)
+	if configFile, interval := config.ConfigFile(), config.ConfigReloadInterval(); configFile != "" && interval > 0 {
+		w.watch(ctx, func() { watchConfigFile(ctx, configFile, interval) })
+	}
+
+	log.Printf("StartServer: starting weaver on %s", w.httpServer.Addr)
 	log.Printf("Keep-Alive: %s", util.BoolToOnOff(keepAliveEnabled))
+	log.Printf("TLS: %s", util.BoolToOnOff(tlsConfig.Enabled()))
+	log.Printf("HTTP/2: %s", util.BoolToOnOff(http2Enabled))
 
-	if err := server.httpServer.ListenAndServe(); err != nil {
+	if err := w.Start(ctx); err != nil {
 		log.Fatalf("StartServer: starting weaver failed with %s", err)
 	}
 }
+
+// serverProtocols always serves HTTP/1.1 and, when enabled, HTTP/2 over TLS and cleartext h2c with
+// prior knowledge
+func serverProtocols(http2Enabled bool) *http.Protocols {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(http2Enabled)
+	protocols.SetUnencryptedHTTP2(http2Enabled)
+
+	return protocols
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gojektech/weaver/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func h2cClient() *http.Client {
+	protocols := &http.Protocols{}
+	protocols.SetUnencryptedHTTP2(true)
+
+	return &http.Client{Transport: &http.Transport{Protocols: protocols}}
+}
+
+func TestServerProtocolsServeH2CWhenHTTP2Enabled(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.Config.Protocols = serverProtocols(true)
+	ts.Start()
+	defer ts.Close()
+
+	res, err := h2cClient().Get(ts.URL)
+	require.NoError(t, err, "should not have failed to make an h2c request")
+	defer res.Body.Close()
+
+	assert.Equal(t, "HTTP/2.0", res.Header.Get("X-Proto"))
+
+	res, err = http.Get(ts.URL)
+	require.NoError(t, err, "should not have failed to make an HTTP/1.1 request")
+	defer res.Body.Close()
+
+	assert.Equal(t, "HTTP/1.1", res.Header.Get("X-Proto"))
+}
+
+func TestServerProtocolsRefuseH2CWhenHTTP2Disabled(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.Config.Protocols = serverProtocols(false)
+	ts.Start()
+	defer ts.Close()
+
+	_, err := h2cClient().Get(ts.URL)
+	assert.Error(t, err, "should have failed to make an h2c request")
+}
+
+func TestStartServerServesUntilShutdownServer(t *testing.T) {
+	for key, value := range map[string]string{"PROXY_PORT": "0", "SERVER_PORT": "0", "SHUTDOWN_DRAIN_PERIOD_IN_MS": "0"} {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	config.Load()
+	defer config.Load()
+	defer setServer(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+	go func() {
+		StartServer(ctx, &staticRouteLoader{})
+		close(started)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for currentServer() == nil || len(currentServer().health.notReadyReasons()) > 0 {
+		require.True(t, time.Now().Before(deadline), "should have started the server")
+		time.Sleep(time.Millisecond)
+	}
+
+	require.NoError(t, ShutdownServer(context.Background()))
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartServer should have returned once the server shut down")
+	}
+
+	cancel()
+	WaitServer()
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type slowRequest struct {
+	res  *http.Response
+	body string
+	err  error
+}
+
+// startShutdownTestWeaver serves a weaver proxying every request to backendURL on a local listener
+func startShutdownTestWeaver(t *testing.T, backendURL string) (*Weaver, string) {
+	config.Load()
+	logger.SetupLogger()
+
+	acl := staticACL(t, "svc-slow", "/", backendURL)
+
+	router := NewRouter(&mockRouteLoader{})
+	require.NoError(t, router.upsertACL(acl))
+	atomic.StoreInt32(&router.bootstrapped, 1)
+	atomic.StoreInt32(&router.watching, 1)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	weaverHealth := &health{router: router}
+	w := &Weaver{
+		httpServer:  &http.Server{Handler: &proxy{router: router}},
+		adminServer: &http.Server{Handler: newAdminHandler(nil, weaverHealth)},
+		router:      router,
+		health:      weaverHealth,
+	}
+
+	go w.httpServer.Serve(listener)
+
+	return w, "http://" + listener.Addr().String()
+}
+
+func readiness(w *Weaver) int {
+	rec := httptest.NewRecorder()
+	w.adminServer.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/health/ready", nil))
+
+	return rec.Code
+}
+
+func slowBackend(delay time.Duration, received chan<- struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		time.Sleep(delay)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("slow response"))
+	}))
+}
+
+func getAsync(url string) <-chan slowRequest {
+	done := make(chan slowRequest, 1)
+
+	go func() {
+		res, err := http.Get(url)
+		if err != nil {
+			done <- slowRequest{err: err}
+			return
+		}
+		defer res.Body.Close()
+
+		body, err := ioutil.ReadAll(res.Body)
+		done <- slowRequest{res: res, body: string(body), err: err}
+	}()
+
+	return done
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	received := make(chan struct{}, 1)
+	backend := slowBackend(300*time.Millisecond, received)
+	defer backend.Close()
+
+	w, url := startShutdownTestWeaver(t, backend.URL)
+	assert.Equal(t, http.StatusOK, readiness(w))
+
+	inFlight := getAsync(url + "/orders")
+	<-received
+
+	shutdownDone := make(chan error, 1)
+	go func() { shutdownDone <- w.shutdown(context.Background(), 100*time.Millisecond, 2*time.Second) }()
+
+	// readiness fails as soon as draining starts, while weaver still serves
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, readiness(w))
+
+	res, err := http.Get(url + "/orders")
+	require.NoError(t, err, "should still accept connections while draining")
+	res.Body.Close()
+
+	<-received
+	require.NoError(t, <-shutdownDone)
+
+	completed := <-inFlight
+	require.NoError(t, completed.err, "in-flight request should not have been cut")
+	assert.Equal(t, http.StatusOK, completed.res.StatusCode)
+	assert.Equal(t, "slow response", completed.body)
+
+	_, err = http.Get(url + "/orders")
+	assert.Error(t, err, "should not accept connections after shutdown")
+}
+
+func TestShutdownGivesUpOnRequestsPastTheTimeout(t *testing.T) {
+	received := make(chan struct{}, 1)
+	backend := slowBackend(time.Second, received)
+	defer backend.Close()
+
+	w, url := startShutdownTestWeaver(t, backend.URL)
+
+	inFlight := getAsync(url + "/orders")
+	<-received
+
+	err := w.shutdown(context.Background(), 0, 100*time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	<-inFlight
+}
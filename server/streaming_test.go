@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamingProxy(t *testing.T, backendURL string, flushIntervalInMS int64) *httptest.Server {
+	config.Load()
+	logger.SetupLogger()
+
+	acl := staticACL(t, "order-tracking", "/orders/", backendURL)
+	acl.FlushIntervalInMS = flushIntervalInMS
+
+	rtr := NewRouter(&mockRouteLoader{})
+	require.NoError(t, rtr.upsertACL(acl), "should not have failed to add the route")
+
+	return httptest.NewServer(Recover(&proxy{router: rtr}))
+}
+
+// readWithin fails the test when the reader does not deliver within a second, which means the
+// response is being buffered somewhere between the backend and the client
+func readWithin(t *testing.T, read func() (string, error)) string {
+	type result struct {
+		value string
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		value, err := read()
+		done <- result{value, err}
+	}()
+
+	select {
+	case res := <-done:
+		require.NoError(t, res.err, "should not have failed to read from the stream")
+		return res.value
+	case <-time.After(time.Second):
+		require.FailNow(t, "should have received the data before the backend finished the response")
+		return ""
+	}
+}
+
+func TestProxyDeliversServerSentEventsIncrementally(t *testing.T) {
+	next := make(chan struct{})
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		for idx := 1; idx <= 3; idx++ {
+			fmt.Fprintf(w, "event: location\ndata: %d\n\n", idx)
+			w.(http.Flusher).Flush()
+
+			<-next
+		}
+	}))
+	defer backend.Close()
+
+	ts := newStreamingProxy(t, backend.URL, -1)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/orders/1234")
+	require.NoError(t, err, "should not have failed to open the stream")
+	defer res.Body.Close()
+
+	assert.Equal(t, []string{"text/event-stream"}, res.Header["Content-Type"])
+
+	events := bufio.NewReader(res.Body)
+	for idx := 1; idx <= 3; idx++ {
+		readWithin(t, func() (string, error) { return events.ReadString('\n') })
+		data := readWithin(t, func() (string, error) { return events.ReadString('\n') })
+		readWithin(t, func() (string, error) { return events.ReadString('\n') })
+
+		assert.Equal(t, fmt.Sprintf("data: %d\n", idx), data)
+		next <- struct{}{}
+	}
+}
+
+func TestProxyFlushesWithACLFlushInterval(t *testing.T) {
+	finish := make(chan struct{})
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.WriteHeader(http.StatusOK)
+
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+
+		<-finish
+		w.Write([]byte("world"))
+	}))
+	defer backend.Close()
+
+	ts := newStreamingProxy(t, backend.URL, -1)
+	defer ts.Close()
+	defer close(finish)
+
+	first := readWithin(t, func() (string, error) {
+		res, err := http.Get(ts.URL + "/orders/1234")
+		if err != nil {
+			return "", err
+		}
+		defer res.Body.Close()
+
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(res.Body, buf)
+		return string(buf), err
+	})
+
+	assert.Equal(t, "hello", first)
+}
+
+type closeNotifyingRecorder struct {
+	*httptest.ResponseRecorder
+	closed chan bool
+}
+
+func (r closeNotifyingRecorder) CloseNotify() <-chan bool {
+	return r.closed
+}
+
+func TestWrapperResponseWriterPreservesOptionalInterfaces(t *testing.T) {
+	recorder := closeNotifyingRecorder{httptest.NewRecorder(), make(chan bool, 1)}
+	rw := &wrapperResponseWriter{ResponseWriter: recorder}
+
+	recorder.closed <- true
+	assert.True(t, <-rw.CloseNotify())
+
+	n, err := rw.ReadFrom(bufio.NewReader(io.LimitReader(neverEnding('x'), 3)))
+	require.NoError(t, err, "should not have failed to copy the body")
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, "xxx", recorder.Body.String())
+
+	rw.Flush()
+	assert.True(t, recorder.Flushed)
+
+	assert.Equal(t, recorder, rw.Unwrap())
+}
+
+type neverEnding byte
+
+func (b neverEnding) Read(p []byte) (int, error) {
+	for idx := range p {
+		p[idx] = byte(b)
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojektech/weaver"
+	"github.com/gojektech/weaver/config"
+	"github.com/gojektech/weaver/pkg/instrumentation"
+	"github.com/gojektech/weaver/pkg/logger"
+	newrelic "github.com/newrelic/go-agent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upgradeEchoBackend switches to a raw echo protocol, prefixing every echo with its name
+func upgradeEchoBackend(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+
+		buf := make([]byte, 1024)
+		for {
+			n, err := brw.Read(buf)
+			if err != nil {
+				return
+			}
+
+			brw.WriteString(name + ":" + string(buf[:n]))
+			brw.Flush()
+		}
+	}))
+}
+
+func dialUpgrade(t *testing.T, proxyURL, driverID string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", proxyURL[len("http://"):])
+	require.NoError(t, err, "should not have failed to connect to weaver")
+
+	fmt.Fprintf(conn, "GET /drivers/stream HTTP/1.1\r\nHost: weaver\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nX-Driver-Id: %s\r\n\r\n", driverID)
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err, "should not have failed to read the handshake response")
+	require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+
+	return conn, reader
+}
+
+func echo(t *testing.T, conn net.Conn, reader *bufio.Reader, message, expected string) {
+	_, err := conn.Write([]byte(message))
+	require.NoError(t, err, "should not have failed to send a message")
+
+	received := make([]byte, len(expected))
+	_, err = io.ReadFull(reader, received)
+	require.NoError(t, err, "should not have failed to receive the echo")
+
+	assert.Equal(t, expected, string(received))
+}
+
+func TestProxyPinsUpgradedConnectionsToShard(t *testing.T) {
+	config.Load()
+	logger.SetupLogger()
+
+	backendA, backendB := upgradeEchoBackend("a"), upgradeEchoBackend("b")
+	defer backendA.Close()
+	defer backendB.Close()
+
+	acl := withEndpoint(t, &weaver.ACL{
+		ID:        "drivers-stream",
+		Criterion: "Method(`GET`) && Path(`/drivers/stream`)",
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:   "header",
+			ShardExpr: "X-Driver-Id",
+			ShardFunc: "lookup",
+			ShardConfig: json.RawMessage(fmt.Sprintf(`{
+				"1": { "backend_name": "a", "backend": "%s" },
+				"2": { "backend_name": "b", "backend": "%s" }
+			}`, backendA.URL, backendB.URL)),
+		},
+	})
+
+	rtr := NewRouter(&mockRouteLoader{})
+	require.NoError(t, rtr.upsertACL(acl), "should not have failed to add the route")
+
+	ts := httptest.NewServer(Recover(&proxy{router: rtr}))
+	defer ts.Close()
+
+	connB, readerB := dialUpgrade(t, ts.URL, "2")
+	connA, readerA := dialUpgrade(t, ts.URL, "1")
+
+	echo(t, connB, readerB, "hello", "b:hello")
+	echo(t, connA, readerA, "hello", "a:hello")
+	echo(t, connB, readerB, "again", "b:again")
+
+	assert.Equal(t, 2, instrumentation.Default().ActiveUpgradedConnections(acl.ID))
+
+	connA.Close()
+	connB.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for instrumentation.Default().ActiveUpgradedConnections(acl.ID) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, 0, instrumentation.Default().ActiveUpgradedConnections(acl.ID), "should have tracked the closed connections")
+}
+
+func TestWrapperResponseWriterHijackFailsWithoutHijacker(t *testing.T) {
+	rw := &wrapperResponseWriter{ResponseWriter: httptest.NewRecorder()}
+
+	_, _, err := rw.Hijack()
+	assert.Error(t, err, "should have failed to hijack a recorder")
+	assert.False(t, rw.hijacked)
+}
+
+func TestIsUpgradeRequest(t *testing.T) {
+	upgrades := []struct {
+		connection string
+		upgrade    string
+		expected   bool
+	}{
+		{"Upgrade", "websocket", true},
+		{"keep-alive, upgrade", "websocket", true},
+		{"", "websocket", false},
+		{"keep-alive", "x", false},
+		{"Upgrade", "", false},
+	}
+
+	for _, tt := range upgrades {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Connection", tt.connection)
+		r.Header.Set("Upgrade", tt.upgrade)
+
+		assert.Equal(t, tt.expected, isUpgradeRequest(r), "Connection: %q, Upgrade: %q", tt.connection, tt.upgrade)
+	}
+}
+
+type endRecordingTransaction struct {
+	newrelic.Transaction
+	ended int32
+}
+
+func (txn *endRecordingTransaction) End() error {
+	atomic.StoreInt32(&txn.ended, 1)
+	return nil
+}
+
+func TestNewRelicUpgradeWriterEndsTheTransactionOnHijack(t *testing.T) {
+	txn := &endRecordingTransaction{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := (&newRelicUpgradeWriter{ResponseWriter: w, transaction: txn}).Hijack()
+		require.NoError(t, err)
+		conn.Close()
+	}))
+	defer server.Close()
+
+	_, err := http.Get(server.URL)
+	assert.Error(t, err, "should have had its connection hijacked")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&txn.ended), "should have ended the transaction")
+
+	_, _, err = (&newRelicUpgradeWriter{ResponseWriter: httptest.NewRecorder(), transaction: txn}).Hijack()
+	assert.Error(t, err, "should have failed to hijack a recorder")
+}
@@ -0,0 +1,370 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gojektech/weaver"
+	"github.com/gojektech/weaver/pkg/accesslog"
+	"github.com/gojektech/weaver/pkg/instrumentation"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Weaver - A weaver proxy, routing and sharding requests by the ACLs of its route loader. Any number
+// of them can run in one process, each with its own routes, metrics and listeners.
+type Weaver struct {
+	loader          RouteLoader
+	metrics         *instrumentation.Metrics
+	logger          *logrus.Logger
+	accessLog       *accesslog.Logger
+	errorTemplates  weaver.ErrorTemplates
+	middlewares     []func(http.Handler) http.Handler
+	plugins         weaver.PluginChain
+	configureServer []func(*http.Server)
+	minACLs         int
+	drainPeriod     time.Duration
+	shutdownTimeout time.Duration
+
+	httpServer  *http.Server
+	adminServer *http.Server
+	router      *Router
+	health      *health
+	handler     http.Handler
+
+	watchersMu sync.Mutex
+	watchers   sync.WaitGroup
+}
+
+// How long weaver waits before loading routes again after the route loader failed, doubling up to the max
+var (
+	routesMinBackoff = time.Second
+	routesMaxBackoff = 30 * time.Second
+)
+
+// Option - Configures a Weaver created with New
+type Option func(*Weaver)
+
+// WithRouteLoader - Where the ACLs are loaded from and watched, required
+func WithRouteLoader(loader RouteLoader) Option {
+	return func(w *Weaver) {
+		w.loader = loader
+	}
+}
+
+// WithMetrics - Where metrics are sent, statsd as set up from STATSD_* by default
+func WithMetrics(sink instrumentation.Sink) Option {
+	return func(w *Weaver) {
+		w.metrics = instrumentation.NewMetrics(sink)
+	}
+}
+
+// WithLogger - Where errors about requests are logged, the logger set up from LOGGER_LEVEL by default
+func WithLogger(l *logrus.Logger) Option {
+	return func(w *Weaver) {
+		w.logger = l
+	}
+}
+
+// WithAccessLog - Where requests are access logged, the logger set up from ACCESS_LOG_* by default
+func WithAccessLog(l *accesslog.Logger) Option {
+	return func(w *Weaver) {
+		w.accessLog = l
+	}
+}
+
+// WithErrorTemplates - The error templates applied to every ACL
+func WithErrorTemplates(templates weaver.ErrorTemplates) Option {
+	return func(w *Weaver) {
+		w.errorTemplates = templates
+	}
+}
+
+// WithAddress - The address the proxy listens on with Start, :8081 by default
+func WithAddress(addr string) Option {
+	return func(w *Weaver) {
+		w.httpServer.Addr = addr
+	}
+}
+
+// WithAdminAddress - The address the admin server listens on with Start, it is not started without one
+func WithAdminAddress(addr string) Option {
+	return func(w *Weaver) {
+		w.adminServer.Addr = addr
+	}
+}
+
+// WithMiddlewares - Wraps the proxy handler, the first middleware being the outermost
+func WithMiddlewares(middlewares ...func(http.Handler) http.Handler) Option {
+	return func(w *Weaver) {
+		w.middlewares = append(w.middlewares, middlewares...)
+	}
+}
+
+// WithPlugins - Plugins run on every request, the only ones run before routing, and before the
+// plugins of the ACL the request is routed to
+func WithPlugins(plugins ...weaver.Plugin) Option {
+	return func(w *Weaver) {
+		w.plugins = append(w.plugins, plugins...)
+	}
+}
+
+// WithHTTPServer - Tunes the proxy's http.Server, e.g. its timeouts or TLS config
+func WithHTTPServer(configure func(*http.Server)) Option {
+	return func(w *Weaver) {
+		w.configureServer = append(w.configureServer, configure)
+	}
+}
+
+// WithReadinessMinACLs - How many ACLs must be loaded for weaver to report ready
+func WithReadinessMinACLs(minACLs int) Option {
+	return func(w *Weaver) {
+		w.minACLs = minACLs
+	}
+}
+
+// WithShutdown - How long Shutdown keeps serving with failing readiness, and then waits for in-flight requests
+func WithShutdown(drainPeriod, timeout time.Duration) Option {
+	return func(w *Weaver) {
+		w.drainPeriod, w.shutdownTimeout = drainPeriod, timeout
+	}
+}
+
+// New - Creates a weaver from its options, without loading routes or listening until it is started
+func New(opts ...Option) (*Weaver, error) {
+	w := &Weaver{
+		metrics:         instrumentation.Default(),
+		httpServer:      &http.Server{Addr: ":8081"},
+		adminServer:     &http.Server{},
+		shutdownTimeout: 30 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.loader == nil {
+		return nil, errors.New("a route loader is required")
+	}
+
+	if err := w.errorTemplates.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid error templates")
+	}
+
+	w.router = NewRouter(w.loader)
+	w.health = &health{router: w.router, minACLs: w.minACLs}
+
+	var handler http.Handler = &proxy{router: w.router, accessLog: w.accessLog, plugins: w.plugins}
+	for i := len(w.middlewares) - 1; i >= 0; i-- {
+		handler = w.middlewares[i](handler)
+	}
+
+	w.handler = w.withInstance(Recover(handler))
+	w.httpServer.Handler = w.handler
+	w.adminServer.Handler = newAdminHandler(nil, w.health)
+
+	for _, configure := range w.configureServer {
+		configure(w.httpServer)
+	}
+
+	return w, nil
+}
+
+// Handler - The proxy handler, for serving weaver from an existing server; routes load once started
+// with Start, Serve or LoadRoutes
+func (w *Weaver) Handler() http.Handler {
+	return w.handler
+}
+
+// AdminHandler - The handler of the admin server, serving liveness and readiness
+func (w *Weaver) AdminHandler() http.Handler {
+	return w.adminServer.Handler
+}
+
+// LoadRoutes - Loads the routes and watches them for updates until ctx is done. Weaver is not ready
+// until the routes load, a failure is returned and loading is retried in the background.
+func (w *Weaver) LoadRoutes(ctx context.Context) error {
+	err := w.router.BootstrapRoutes(ctx)
+	w.watch(ctx, func() { w.keepRoutesLoaded(ctx, err == nil) })
+
+	return err
+}
+
+// Wait - Waits for the route watcher, and the other watchers of the weaver, to stop once the context
+// they were started with is done
+func (w *Weaver) Wait() {
+	// a watcher started concurrently is either counted before Wait or sees its context done
+	w.watchersMu.Lock()
+	w.watchersMu.Unlock()
+
+	w.watchers.Wait()
+}
+
+// watch runs the watcher in the background until ctx is done, nothing is started once it is
+func (w *Weaver) watch(ctx context.Context, watcher func()) {
+	w.watchersMu.Lock()
+	defer w.watchersMu.Unlock()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	w.watchers.Add(1)
+	go func() {
+		defer w.watchers.Done()
+		watcher()
+	}()
+}
+
+// keepRoutesLoaded watches route updates until ctx is done. A failed bootstrap is retried, and a
+// watcher that stops is restarted once every route is reloaded, with backoff so readiness recovers
+// as soon as the route loader does.
+func (w *Weaver) keepRoutesLoaded(ctx context.Context, bootstrapped bool) {
+	backoff := routesMinBackoff
+
+	for {
+		if !bootstrapped {
+			if err := w.router.BootstrapRoutes(ctx); err != nil {
+				log.Printf("Weaver: failed to load routes, retrying in %s: %s", backoff, err)
+				if !sleepContext(ctx, backoff) {
+					return
+				}
+
+				backoff = nextBackoff(backoff)
+				continue
+			}
+
+			log.Printf("Weaver: loaded routes")
+			bootstrapped = true
+		}
+
+		watchStart := time.Now()
+		w.router.WatchRouteUpdates(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// a watcher that ran for a while failed on its own, not because the loader is still down
+		if time.Since(watchStart) > routesMaxBackoff {
+			backoff = routesMinBackoff
+		}
+
+		log.Printf("Weaver: route watcher stopped, reloading routes in %s", backoff)
+		if !sleepContext(ctx, backoff) {
+			return
+		}
+
+		backoff = nextBackoff(backoff)
+		bootstrapped = false
+	}
+}
+
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > routesMaxBackoff {
+		return routesMaxBackoff
+	}
+
+	return backoff
+}
+
+// sleepContext waits for the duration, false when ctx is done first
+func sleepContext(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Start - Loads the routes and serves the proxy on its address, and the admin server when it has
+// one, until Shutdown
+func (w *Weaver) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", w.httpServer.Addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen on %s", w.httpServer.Addr)
+	}
+
+	return w.Serve(ctx, listener)
+}
+
+// Serve - Loads the routes and serves the proxy on the listener, and the admin server when it has an
+// address, until Shutdown
+func (w *Weaver) Serve(ctx context.Context, listener net.Listener) error {
+	if err := w.LoadRoutes(ctx); err != nil {
+		log.Printf("Weaver: failed to load routes, not ready until they do: %s", err)
+	} else {
+		log.Printf("Weaver: loaded routes")
+	}
+
+	if w.adminServer.Addr != "" {
+		go func() {
+			log.Printf("Weaver: starting admin server on %s", w.adminServer.Addr)
+			if err := w.adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Weaver: admin server failed with %s", err)
+			}
+		}()
+	}
+
+	var err error
+	if w.httpServer.TLSConfig != nil {
+		err = w.httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = w.httpServer.Serve(listener)
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown - Stops weaver gracefully: readiness fails first so load balancers stop sending requests,
+// then after the drain period the listeners close and in-flight requests get until the timeout
+func (w *Weaver) Shutdown(ctx context.Context) error {
+	return w.shutdown(ctx, w.drainPeriod, w.shutdownTimeout)
+}
+
+func (w *Weaver) shutdown(ctx context.Context, drainPeriod, timeout time.Duration) error {
+	w.health.drain()
+
+	log.Printf("Weaver: draining for %s", drainPeriod)
+	select {
+	case <-time.After(drainPeriod):
+	case <-ctx.Done():
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	log.Printf("Weaver: waiting up to %s for in-flight requests", timeout)
+	err := w.httpServer.Shutdown(ctx)
+	if adminErr := w.adminServer.Shutdown(ctx); err == nil {
+		err = adminErr
+	}
+
+	return err
+}
+
+// withInstance hands the weaver's metrics, logger and error templates to the request
+func (w *Weaver) withInstance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := instrumentation.NewMetricsContext(r.Context(), w.metrics)
+		ctx = withErrorTemplates(ctx, w.errorTemplates)
+
+		if w.logger != nil {
+			ctx = logger.NewContext(ctx, w.logger)
+		}
+
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
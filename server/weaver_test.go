@@ -0,0 +1,243 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gojektech/weaver"
+	"github.com/gojektech/weaver/pkg/logger"
+	"github.com/gojektech/weaver/pkg/shard"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticRouteLoader loads a fixed set of ACLs and never updates them
+type staticRouteLoader struct {
+	acls []*weaver.ACL
+}
+
+func (srl *staticRouteLoader) BootstrapRoutes(ctx context.Context, upsert UpsertRouteFunc) error {
+	for _, acl := range srl.acls {
+		if err := upsert(acl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (srl *staticRouteLoader) WatchRoutes(ctx context.Context, upsert UpsertRouteFunc, del DeleteRouteFunc) {
+	<-ctx.Done()
+}
+
+type recordingSink struct {
+	mu      sync.Mutex
+	buckets []string
+}
+
+func (rs *recordingSink) Increment(bucket string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.buckets = append(rs.buckets, bucket)
+}
+
+func (rs *recordingSink) Gauge(bucket string, value int)               {}
+func (rs *recordingSink) Timing(bucket string, duration time.Duration) {}
+
+func (rs *recordingSink) recorded() []string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return append([]string{}, rs.buckets...)
+}
+
+// staticACL routes the paths under path to backendURL
+func staticACL(t *testing.T, id, path, backendURL string) *weaver.ACL {
+	return withEndpoint(t, &weaver.ACL{
+		ID:        id,
+		Criterion: fmt.Sprintf("PathRegexp(`%s.*`)", path),
+		EndpointConfig: &weaver.EndpointConfig{
+			Matcher:     "path",
+			ShardExpr:   "/(.*)",
+			ShardFunc:   "none",
+			ShardConfig: json.RawMessage(fmt.Sprintf(`{"backend_name": "%s-backend", "backend": "%s"}`, id, backendURL)),
+		},
+	})
+}
+
+// withEndpoint sets up the endpoint of acl from its EndpointConfig, as loading the ACL would
+func withEndpoint(t *testing.T, acl *weaver.ACL) *weaver.ACL {
+	sharder, err := shard.New(acl.EndpointConfig.ShardFunc, acl.EndpointConfig.ShardConfig)
+	require.NoError(t, err, "should not have failed to init a sharder")
+
+	acl.Endpoint, err = weaver.NewEndpoint(acl.EndpointConfig, sharder)
+	require.NoError(t, err, "should not have failed to set endpoint")
+
+	return acl
+}
+
+func TestNewRequiresARouteLoader(t *testing.T) {
+	_, err := New()
+	assert.EqualError(t, err, "a route loader is required")
+}
+
+func TestNewRejectsInvalidErrorTemplates(t *testing.T) {
+	templates := weaver.ErrorTemplates{weaver.ErrorDefaultTemplate: {JSON: "{{.Code"}}
+
+	_, err := New(WithRouteLoader(&staticRouteLoader{}), WithErrorTemplates(templates))
+	assert.Error(t, err)
+}
+
+func TestIndependentWeaversInOneProcess(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello from " + r.URL.Path))
+	}))
+	defer backend.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ordersSink, usersSink := &recordingSink{}, &recordingSink{}
+
+	orders, err := New(
+		WithRouteLoader(&staticRouteLoader{acls: []*weaver.ACL{staticACL(t, "orders", "/orders", backend.URL)}}),
+		WithMetrics(ordersSink),
+	)
+	require.NoError(t, err)
+
+	users, err := New(
+		WithRouteLoader(&staticRouteLoader{acls: []*weaver.ACL{staticACL(t, "users", "/users", backend.URL)}}),
+		WithMetrics(usersSink),
+		WithErrorTemplates(weaver.ErrorTemplates{weaver.ErrorDefaultTemplate: {Status: http.StatusTeapot}}),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, orders.LoadRoutes(ctx))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() { served <- users.Serve(ctx, listener) }()
+
+	w := httptest.NewRecorder()
+	orders.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/orders/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello from /orders/1", w.Body.String())
+
+	w = httptest.NewRecorder()
+	orders.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/users/1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code, "should not have routed with the other weaver's ACLs")
+
+	res, err := http.Get("http://" + listener.Addr().String() + "/users/1")
+	require.NoError(t, err)
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "hello from /users/1", string(body))
+
+	res, err = http.Get("http://" + listener.Addr().String() + "/orders/1")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusTeapot, res.StatusCode, "should have used its own error templates")
+
+	assert.Contains(t, ordersSink.recorded(), "request.api.orders.count")
+	assert.NotContains(t, ordersSink.recorded(), "request.api.users.count")
+	assert.Contains(t, usersSink.recorded(), "request.api.users.count")
+	assert.Contains(t, usersSink.recorded(), "request.internal.404.count")
+
+	require.NoError(t, users.shutdown(context.Background(), 0, time.Second))
+	assert.NoError(t, <-served)
+}
+
+func TestACLLogLevelOverridesTheLevelOfTheLogger(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	backend.Close()
+
+	traced := staticACL(t, "traced", "/traced", backend.URL)
+	traced.LogLevel = "error"
+
+	var logs bytes.Buffer
+	l := logger.New(logrus.FatalLevel)
+	l.Out = &logs
+
+	w, err := New(
+		WithRouteLoader(&staticRouteLoader{acls: []*weaver.ACL{traced, staticACL(t, "quiet", "/quiet", backend.URL)}}),
+		WithLogger(l),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, w.LoadRoutes(ctx))
+
+	for _, path := range []string{"/traced/1", "/quiet/1"} {
+		rec := httptest.NewRecorder()
+		w.Handler().ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	}
+
+	assert.Contains(t, logs.String(), "for acl traced")
+	assert.NotContains(t, logs.String(), "for acl quiet")
+}
+
+func TestACLsWithAnInvalidLogLevelAreRejected(t *testing.T) {
+	acl := staticACL(t, "orders", "/orders", "http://localhost")
+	acl.LogLevel = "verbose"
+
+	w, err := New(WithRouteLoader(&staticRouteLoader{acls: []*weaver.ACL{acl}}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	assert.Error(t, w.LoadRoutes(ctx))
+}
+
+// slowStoppingRouteLoader takes a while to stop watching once its context is done
+type slowStoppingRouteLoader struct {
+	staticRouteLoader
+	stopped int32
+}
+
+func (ssrl *slowStoppingRouteLoader) WatchRoutes(ctx context.Context, upsert UpsertRouteFunc, del DeleteRouteFunc) {
+	<-ctx.Done()
+	time.Sleep(50 * time.Millisecond)
+	atomic.StoreInt32(&ssrl.stopped, 1)
+}
+
+func TestWaitWaitsForTheRouteWatcherToStop(t *testing.T) {
+	loader := &slowStoppingRouteLoader{}
+
+	w, err := New(WithRouteLoader(loader))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, w.LoadRoutes(ctx))
+
+	for !w.router.Watching() {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	w.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loader.stopped), "should have waited for the route watcher")
+
+	// nothing is started for a context that is done
+	require.NoError(t, w.LoadRoutes(ctx))
+	w.Wait()
+}
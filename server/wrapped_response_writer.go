@@ -1,9 +1,18 @@
 package server
 
-import "net/http"
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+)
 
 type wrapperResponseWriter struct {
-	statusCode int
+	statusCode   int
+	bytesWritten int64
+	hijacked     bool
+	onHijack     func()
 	http.ResponseWriter
 }
 
@@ -12,10 +21,107 @@ func (w *wrapperResponseWriter) Header() http.Header {
 }
 
 func (w *wrapperResponseWriter) Write(data []byte) (int, error) {
-	return w.ResponseWriter.Write(data)
+	n, err := w.ResponseWriter.Write(data)
+	w.bytesWritten += int64(n)
+
+	return n, err
 }
 
 func (w *wrapperResponseWriter) WriteHeader(statusCode int) {
 	w.statusCode = statusCode
 	w.ResponseWriter.WriteHeader(statusCode)
 }
+
+func (w *wrapperResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// CloseNotify is kept for handlers still relying on http.CloseNotifier
+func (w *wrapperResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+
+	return make(chan bool)
+}
+
+func (w *wrapperResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	var n int64
+	var err error
+	if readerFrom, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = readerFrom.ReadFrom(src)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, src)
+	}
+
+	w.bytesWritten += n
+	return n, err
+}
+
+// status is the status code sent to the client, a response written without WriteHeader is a 200
+func (w *wrapperResponseWriter) status() int {
+	if w.statusCode == 0 && (w.bytesWritten > 0 || w.hijacked) {
+		return http.StatusOK
+	}
+
+	return w.statusCode
+}
+
+// Unwrap lets http.ResponseController reach the underlying response writer
+func (w *wrapperResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack hands the client connection over for protocol upgrades. The reverse proxy only hijacks
+// once the backend has switched protocols, so the connection is recorded as upgraded.
+func (w *wrapperResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w.statusCode = http.StatusSwitchingProtocols
+	w.hijacked = true
+
+	if w.onHijack != nil {
+		w.onHijack()
+	}
+
+	return conn, rw, nil
+}
+
+// writerOnly hides ReadFrom so io.Copy does not loop back into it
+type writerOnly struct {
+	io.Writer
+}
+
+// countingReadCloser counts the bytes of the request body read while proxying it
+type countingReadCloser struct {
+	io.ReadCloser
+	bytesRead int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.bytesRead += int64(n)
+
+	return n, err
+}
+
+func countRequestBody(r *http.Request) *countingReadCloser {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	body := &countingReadCloser{ReadCloser: r.Body}
+	r.Body = body
+
+	return body
+}
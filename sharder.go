@@ -3,3 +3,8 @@ package weaver
 type Sharder interface {
 	Shard(key string) (*Backend, error)
 }
+
+// BackendLister - A sharder listing its backends, so their connections are closed once it is replaced
+type BackendLister interface {
+	Backends() []*Backend
+}
@@ -0,0 +1,195 @@
+package weaver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUpstreamTimeout - The backend did not answer within the request or response header timeout
+var ErrUpstreamTimeout = errors.New("upstream timed out")
+
+// TimeoutConfig - Timeouts for proxying a request in milliseconds, 0 leaves a timeout unset. An ACL
+// sets them for all its backends and a backend definition overrides them one by one.
+type TimeoutConfig struct {
+	ConnectInMS        int64 `json:"connect_in_ms,omitempty"`
+	TLSHandshakeInMS   int64 `json:"tls_handshake_in_ms,omitempty"`
+	ResponseHeaderInMS int64 `json:"response_header_in_ms,omitempty"`
+	RequestInMS        int64 `json:"request_in_ms,omitempty"`
+}
+
+// Override - Returns the timeouts with the ones set in override taking precedence, either may be nil
+func (tc *TimeoutConfig) Override(override *TimeoutConfig) TimeoutConfig {
+	var merged TimeoutConfig
+	if tc != nil {
+		merged = *tc
+	}
+
+	if override == nil {
+		return merged
+	}
+
+	if override.ConnectInMS != 0 {
+		merged.ConnectInMS = override.ConnectInMS
+	}
+
+	if override.TLSHandshakeInMS != 0 {
+		merged.TLSHandshakeInMS = override.TLSHandshakeInMS
+	}
+
+	if override.ResponseHeaderInMS != 0 {
+		merged.ResponseHeaderInMS = override.ResponseHeaderInMS
+	}
+
+	if override.RequestInMS != 0 {
+		merged.RequestInMS = override.RequestInMS
+	}
+
+	return merged
+}
+
+func inMS(value int64) time.Duration {
+	return time.Duration(value) * time.Millisecond
+}
+
+type timeoutsCtxKey struct{}
+
+// Response header timer states
+const (
+	headersPending int32 = iota
+	headersTimedOut
+	headersReceived
+)
+
+type requestTimeouts struct {
+	TimeoutConfig
+	headers int32
+}
+
+func requestTimeoutsFrom(ctx context.Context) *requestTimeouts {
+	timeouts, _ := ctx.Value(timeoutsCtxKey{}).(*requestTimeouts)
+	return timeouts
+}
+
+// withTimeouts enforces the request and response header timeouts of the routed ACL and backend
+// through the request context, and hands the connect and TLS handshake timeouts to the dialer.
+// Upstream failures caused by a timeout are reported as ErrUpstreamTimeout.
+func withTimeouts(next http.Handler, backendTimeouts *TimeoutConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var aclTimeouts *TimeoutConfig
+
+		route, routed := RequestRouteFrom(req.Context())
+		if routed {
+			aclTimeouts = route.ACL.Timeouts
+		}
+
+		timeouts := &requestTimeouts{TimeoutConfig: aclTimeouts.Override(backendTimeouts)}
+		ctx := context.WithValue(req.Context(), timeoutsCtxKey{}, timeouts)
+
+		if timeouts.RequestInMS > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, inMS(timeouts.RequestInMS))
+			defer cancel()
+		}
+
+		if timeouts.ResponseHeaderInMS > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+			defer cancel()
+
+			headerTimer := time.AfterFunc(inMS(timeouts.ResponseHeaderInMS), func() {
+				if atomic.CompareAndSwapInt32(&timeouts.headers, headersPending, headersTimedOut) {
+					cancel()
+				}
+			})
+			defer headerTimer.Stop()
+		}
+
+		next.ServeHTTP(w, req.WithContext(ctx))
+
+		if routed && route.UpstreamErr != nil && isTimeout(ctx, timeouts, route.UpstreamErr) {
+			route.UpstreamErr = errors.Wrap(ErrUpstreamTimeout, route.UpstreamErr.Error())
+		}
+	})
+}
+
+func isTimeout(ctx context.Context, timeouts *requestTimeouts, err error) bool {
+	if ctx.Err() == context.DeadlineExceeded || atomic.LoadInt32(&timeouts.headers) == headersTimedOut {
+		return true
+	}
+
+	netErr, ok := errors.Cause(err).(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// responseHeadersReceived disarms the response header timeout of the request
+func responseHeadersReceived(req *http.Request) {
+	if timeouts := requestTimeoutsFrom(req.Context()); timeouts != nil {
+		atomic.CompareAndSwapInt32(&timeouts.headers, headersPending, headersReceived)
+	}
+}
+
+// timeoutDialer dials backends with the connect and TLS handshake timeouts of the request being
+// proxied, falling back to the backend's dialer timeout
+type timeoutDialer struct {
+	connectTimeout time.Duration
+	keepAlive      time.Duration
+	tlsConfig      *tls.Config
+	nextProtos     []string
+}
+
+func (td *timeoutDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   td.connectTimeout,
+		KeepAlive: td.keepAlive,
+		DualStack: true,
+	}
+
+	if timeouts := requestTimeoutsFrom(ctx); timeouts != nil && timeouts.ConnectInMS > 0 {
+		dialer.Timeout = inMS(timeouts.ConnectInMS)
+	}
+
+	return dialer.DialContext(ctx, network, addr)
+}
+
+func (td *timeoutDialer) DialTLSContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := td.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{}
+	if td.tlsConfig != nil {
+		tlsConfig = td.tlsConfig.Clone()
+	}
+
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+
+		tlsConfig.ServerName = host
+	}
+
+	tlsConfig.NextProtos = td.nextProtos
+
+	if timeouts := requestTimeoutsFrom(ctx); timeouts != nil && timeouts.TLSHandshakeInMS > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, inMS(timeouts.TLSHandshakeInMS))
+		defer cancel()
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
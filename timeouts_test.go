@@ -0,0 +1,101 @@
+package weaver
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutConfigOverride(t *testing.T) {
+	acl := &TimeoutConfig{ConnectInMS: 100, ResponseHeaderInMS: 1000, RequestInMS: 5000}
+	backend := &TimeoutConfig{ConnectInMS: 50, TLSHandshakeInMS: 200}
+
+	assert.Equal(t, TimeoutConfig{ConnectInMS: 50, TLSHandshakeInMS: 200, ResponseHeaderInMS: 1000, RequestInMS: 5000}, acl.Override(backend))
+	assert.Equal(t, *acl, acl.Override(nil))
+
+	var unset *TimeoutConfig
+	assert.Equal(t, *backend, unset.Override(backend))
+}
+
+func proxyWithTimeouts(t *testing.T, backendURL string, aclTimeouts, backendTimeouts *TimeoutConfig) (*RequestRoute, *httptest.ResponseRecorder) {
+	backend, err := NewBackend("foobar", backendURL, BackendOptions{Timeout: time.Second, Timeouts: backendTimeouts})
+	require.NoError(t, err, "should not have failed to create new backend")
+
+	route := &RequestRoute{ACL: &ACL{ID: "drivers", Timeouts: aclTimeouts}, Backend: backend}
+
+	w := httptest.NewRecorder()
+	backend.Handler.ServeHTTP(w, WithRequestRoute(httptest.NewRequest("GET", "/drivers", nil), route))
+
+	return route, w
+}
+
+func slowBackend(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestBackendTimesOutOnRequestDeadline(t *testing.T) {
+	ts := slowBackend(time.Second)
+	defer ts.Close()
+
+	route, _ := proxyWithTimeouts(t, ts.URL, &TimeoutConfig{RequestInMS: 50}, nil)
+
+	require.Error(t, route.UpstreamErr, "should have failed the request")
+	assert.Equal(t, ErrUpstreamTimeout, errors.Cause(route.UpstreamErr))
+}
+
+func TestBackendTimeoutsOverrideACLTimeouts(t *testing.T) {
+	ts := slowBackend(100 * time.Millisecond)
+	defer ts.Close()
+
+	route, w := proxyWithTimeouts(t, ts.URL, &TimeoutConfig{ResponseHeaderInMS: 20}, &TimeoutConfig{ResponseHeaderInMS: 1000})
+
+	assert.NoError(t, route.UpstreamErr, "should have waited for the backend's response header timeout")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	route, _ = proxyWithTimeouts(t, ts.URL, &TimeoutConfig{ResponseHeaderInMS: 20}, nil)
+
+	require.Error(t, route.UpstreamErr, "should have timed out waiting for response headers")
+	assert.Equal(t, ErrUpstreamTimeout, errors.Cause(route.UpstreamErr))
+}
+
+func TestBackendTimesOutOnTLSHandshake(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "should not have failed to listen")
+	defer listener.Close()
+
+	// accepts connections but never answers the TLS handshake
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	route, _ := proxyWithTimeouts(t, "https://"+listener.Addr().String(), &TimeoutConfig{TLSHandshakeInMS: 50}, nil)
+
+	require.Error(t, route.UpstreamErr, "should have failed the TLS handshake")
+	assert.Equal(t, ErrUpstreamTimeout, errors.Cause(route.UpstreamErr))
+}
+
+func TestBackendReportsUnreachableUpstream(t *testing.T) {
+	route, _ := proxyWithTimeouts(t, "http://127.0.0.1:1", &TimeoutConfig{RequestInMS: 1000}, nil)
+
+	require.Error(t, route.UpstreamErr, "should have failed to connect")
+	assert.NotEqual(t, ErrUpstreamTimeout, errors.Cause(route.UpstreamErr))
+}
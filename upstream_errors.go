@@ -0,0 +1,82 @@
+package weaver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+// UpstreamFailure - The kind of failure that kept a backend from answering a request
+type UpstreamFailure string
+
+const (
+	UpstreamTimeout         UpstreamFailure = "timeout"
+	UpstreamUnreachable     UpstreamFailure = "unreachable"
+	UpstreamDNSFailure      UpstreamFailure = "dns_failure"
+	UpstreamTLSFailure      UpstreamFailure = "tls_failure"
+	UpstreamConnectionReset UpstreamFailure = "connection_reset"
+	UpstreamError           UpstreamFailure = "error"
+
+	// UpstreamClientCanceled - The client went away before the backend answered, not a failure of the backend
+	UpstreamClientCanceled UpstreamFailure = "client_canceled"
+)
+
+// ClassifyUpstreamError - Tells what kind of failure an error returned while proxying to a backend is
+func ClassifyUpstreamError(err error) UpstreamFailure {
+	if pkgerrors.Cause(err) == context.Canceled || errors.Is(err, context.Canceled) {
+		return UpstreamClientCanceled
+	}
+
+	if pkgerrors.Cause(err) == ErrUpstreamTimeout {
+		return UpstreamTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return UpstreamDNSFailure
+	}
+
+	if isTLSError(err) {
+		return UpstreamTLSFailure
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return UpstreamTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+		return UpstreamUnreachable
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return UpstreamUnreachable
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return UpstreamConnectionReset
+	}
+
+	return UpstreamError
+}
+
+func isTLSError(err error) bool {
+	var (
+		verificationErr *tls.CertificateVerificationError
+		recordErr       tls.RecordHeaderError
+		alertErr        tls.AlertError
+		authorityErr    x509.UnknownAuthorityError
+		hostnameErr     x509.HostnameError
+		invalidErr      x509.CertificateInvalidError
+	)
+
+	return errors.As(err, &verificationErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
@@ -0,0 +1,49 @@
+package weaver
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyUpstreamError(t *testing.T) {
+	dialErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: err}
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		expected UpstreamFailure
+	}{
+		{"timeout", errors.Wrap(ErrUpstreamTimeout, "context deadline exceeded"), UpstreamTimeout},
+		{"dial timeout", dialErr(timeoutError{}), UpstreamTimeout},
+		{"connection refused", dialErr(os.NewSyscallError("connect", syscall.ECONNREFUSED)), UpstreamUnreachable},
+		{"host unreachable", dialErr(os.NewSyscallError("connect", syscall.EHOSTUNREACH)), UpstreamUnreachable},
+		{"dns failure", dialErr(&net.DNSError{Err: "no such host", Name: "backend.invalid", IsNotFound: true}), UpstreamDNSFailure},
+		{"unknown authority", &net.OpError{Op: "remote error", Err: x509.UnknownAuthorityError{}}, UpstreamTLSFailure},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, UpstreamConnectionReset},
+		{"closed mid response", io.ErrUnexpectedEOF, UpstreamConnectionReset},
+		{"client canceled", errors.Wrap(context.Canceled, "proxying"), UpstreamClientCanceled},
+		{"other", errors.New("malformed HTTP response"), UpstreamError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ClassifyUpstreamError(tt.err))
+		})
+	}
+}